@@ -0,0 +1,323 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"code.google.com/p/goauth2/oauth"
+	"code.google.com/p/goauth2/oauth/jwt"
+	"github.com/rakyll/drive/config"
+)
+
+const (
+	// Redirect URL of the headless flow. Nothing listens on it; the user
+	// copies the URL the browser fails to load back into the terminal.
+	// Desktop clients accept any loopback port.
+	headlessRedirectURL = "http://127.0.0.1:1/"
+
+	// How long to wait for the user to complete the authorization.
+	authTimeout = 5 * time.Minute
+)
+
+var (
+	ErrAuthTimeout = errors.New("timed out waiting for authorization")
+)
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (t *tokenResponse) err() error {
+	if t.Error == "" {
+		return nil
+	}
+	if t.ErrorDescription != "" {
+		return fmt.Errorf("%s: %s", t.Error, t.ErrorDescription)
+	}
+	return errors.New(t.Error)
+}
+
+// RetrieveRefreshToken authorizes the user and returns a refresh token.
+// By default, the authorization code is captured by a temporary HTTP
+// listener on the loopback interface. If isHeadless is set, the user
+// authorizes in a browser on any device and pastes the URL it is
+// redirected to, so no listener needs to be reachable.
+func RetrieveRefreshToken(creds *config.Credentials, isHeadless bool) (string, error) {
+	if isHeadless {
+		return retrievePastedRefreshToken(creds)
+	}
+	return retrieveLoopbackRefreshToken(creds)
+}
+
+// authRequest is an authorization request with PKCE.
+type authRequest struct {
+	conf            *oauth.Config
+	state, verifier string
+}
+
+func newAuthRequest(creds *config.Credentials, redirectURL string) *authRequest {
+	conf := newAuthConfig(creds)
+	conf.RedirectURL = redirectURL
+	return &authRequest{conf: conf, state: randomString(), verifier: randomString()}
+}
+
+func (a *authRequest) authURL() string {
+	sum := sha256.Sum256([]byte(a.verifier))
+	return a.conf.AuthCodeURL(a.state) + "&" + url.Values{
+		"code_challenge":        {base64URLEncode(sum[:])},
+		"code_challenge_method": {"S256"},
+	}.Encode()
+}
+
+// code returns the authorization code of the redirect's query.
+func (a *authRequest) code(q url.Values) (string, error) {
+	switch {
+	case q.Get("state") != a.state:
+		return "", errors.New("invalid state")
+	case q.Get("error") != "":
+		return "", errors.New(q.Get("error"))
+	case q.Get("code") == "":
+		return "", errors.New("no authorization code")
+	}
+	return q.Get("code"), nil
+}
+
+func (a *authRequest) exchange(code string) (string, error) {
+	token, err := postToken(a.conf.TokenURL, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"code_verifier": {a.verifier},
+		"redirect_uri":  {a.conf.RedirectURL},
+		"client_id":     {a.conf.ClientId},
+		"client_secret": {a.conf.ClientSecret},
+	})
+	if err != nil {
+		return "", err
+	}
+	return token.RefreshToken, nil
+}
+
+func retrieveLoopbackRefreshToken(creds *config.Credentials) (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	a := newAuthRequest(creds, fmt.Sprintf("http://%s/", l.Addr()))
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		if q.Get("state") != a.state {
+			http.Error(w, "Invalid state.", http.StatusBadRequest)
+			return
+		}
+		code, err := a.code(q)
+		if err != nil {
+			fmt.Fprintln(w, "Authorization failed; you can close this window.")
+			errs <- err
+			return
+		}
+		fmt.Fprintln(w, "Authorization is complete; you can close this window.")
+		codes <- code
+	}))
+
+	fmt.Println("Visit this URL to authorize gd:")
+	fmt.Println(a.authURL())
+	var code string
+	select {
+	case code = <-codes:
+	case err = <-errs:
+		return "", err
+	case <-time.After(authTimeout):
+		return "", ErrAuthTimeout
+	}
+	return a.exchange(code)
+}
+
+func retrievePastedRefreshToken(creds *config.Credentials) (string, error) {
+	a := newAuthRequest(creds, headlessRedirectURL)
+	fmt.Println("Visit this URL on any device to authorize gd:")
+	fmt.Println(a.authURL())
+	fmt.Println("The browser then fails to load a page on 127.0.0.1; copy its address from the address bar.")
+	fmt.Print("Paste the address here: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	u, err := url.Parse(strings.TrimSpace(line))
+	if err != nil {
+		return "", err
+	}
+	code, err := a.code(u.Query())
+	if err != nil {
+		return "", err
+	}
+	return a.exchange(code)
+}
+
+func postToken(tokenURL string, v url.Values) (*tokenResponse, error) {
+	resp, err := http.PostForm(tokenURL, v)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	token := &tokenResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(token); err != nil {
+		return nil, err
+	}
+	if err = token.err(); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// randomString returns a URL safe string of 32 random bytes, suitable
+// for OAuth 2.0 state parameters and PKCE code verifiers.
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64URLEncode(b)
+}
+
+func base64URLEncode(b []byte) string {
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(b), "=")
+}
+
+// serviceAccountKey is the subset of a service account's JSON key that
+// is needed to assert its identity.
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// serviceAccountTransport authorizes requests as a service account,
+// impersonating subject if it is set. The key file is read on the first
+// request and a new access token is asserted whenever the current one
+// expires.
+type serviceAccountTransport struct {
+	keyFile string
+	subject string
+
+	mu    sync.Mutex
+	jwt   *jwt.Token
+	token *oauth.Token
+}
+
+func (t *serviceAccountTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.accessToken()
+	if err != nil {
+		return nil, err
+	}
+	// copy the request, RoundTrippers shouldn't modify the original
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (t *serviceAccountTransport) accessToken() (*oauth.Token, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != nil && !t.token.Expired() {
+		return t.token, nil
+	}
+	if t.jwt == nil {
+		data, err := ioutil.ReadFile(t.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key := &serviceAccountKey{}
+		if err = json.Unmarshal(data, key); err != nil {
+			return nil, fmt.Errorf("invalid service account key %s: %v", t.keyFile, err)
+		}
+		t.jwt = jwt.NewToken(key.ClientEmail, DriveScope, []byte(key.PrivateKey))
+		t.jwt.ClaimSet.Sub = t.subject
+		if key.TokenURI != "" {
+			t.jwt.ClaimSet.Aud = key.TokenURI
+		}
+	}
+	token, err := t.jwt.Assert(&http.Client{})
+	if err != nil {
+		return nil, err
+	}
+	t.token = token
+	return token, nil
+}
+
+// userTransport authorizes requests with the user's refresh token. The
+// secrets are only read from the secret store on the first request, so
+// commands that never reach Drive don't ask for a passphrase.
+type userTransport struct {
+	creds *config.Credentials
+
+	mu sync.Mutex
+	t  *oauth.Transport
+}
+
+func (t *userTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := t.transport()
+	if err != nil {
+		return nil, err
+	}
+	return transport.RoundTrip(req)
+}
+
+func (t *userTransport) transport() (*oauth.Transport, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.t != nil {
+		return t.t, nil
+	}
+	if err := t.creds.LoadSecrets(); err != nil {
+		return nil, err
+	}
+	t.t = &oauth.Transport{
+		Config:    newAuthConfig(t.creds),
+		Transport: http.DefaultTransport,
+		Token: &oauth.Token{
+			RefreshToken: t.creds.RefreshToken,
+			Expiry:       time.Now(),
+		},
+	}
+	return t.t, nil
+}
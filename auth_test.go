@@ -0,0 +1,43 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/rakyll/drive/config"
+)
+
+func TestAuthRequestCode(t *testing.T) {
+	a := newAuthRequest(&config.Credentials{ClientId: "id"}, headlessRedirectURL)
+	tests := []struct {
+		redirect string
+		want     string
+		wantErr  bool
+	}{
+		{headlessRedirectURL + "?state=" + url.QueryEscape(a.state) + "&code=4/abc", "4/abc", false},
+		{headlessRedirectURL + "?state=other&code=4/abc", "", true},
+		{headlessRedirectURL + "?state=" + url.QueryEscape(a.state) + "&error=access_denied", "", true},
+		{headlessRedirectURL + "?state=" + url.QueryEscape(a.state), "", true},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.redirect)
+		got, err := a.code(u.Query())
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("code(%s) = %q, %v; want %q, error %t", tt.redirect, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// MIME types of the formats native Google documents can be exported to.
+var exportMimeTypes = map[string]string{
+	"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"odt":  "application/vnd.oasis.opendocument.text",
+	"ods":  "application/x-vnd.oasis.opendocument.spreadsheet",
+	"odp":  "application/vnd.oasis.opendocument.presentation",
+	"pdf":  "application/pdf",
+	"rtf":  "application/rtf",
+	"txt":  "text/plain",
+	"html": "text/html",
+	"csv":  "text/csv",
+	"svg":  "image/svg+xml",
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+}
+
+// Cat writes the content of the remote file at Path, or of the given
+// revision if revisionId is set, to w. Native Google documents are
+// exported to the first of the formats they support.
+func (g *Commands) Cat(w io.Writer, formats []string, revisionId string) (err error) {
+	var f *File
+	if f, err = g.remoteFile(); err != nil {
+		return
+	}
+	var body io.ReadCloser
+	switch {
+	case revisionId != "":
+		body, err = g.rem.DownloadRevision(f.Id, revisionId)
+	case f.BlobAt != "":
+		body, err = g.rem.Download(f.Id)
+	default:
+		body, err = g.export(f, formats)
+	}
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return
+}
+
+func (g *Commands) export(f *File, formats []string) (io.ReadCloser, error) {
+	for _, format := range formats {
+		mimeType, ok := exportMimeTypes[strings.ToLower(format)]
+		if !ok {
+			mimeType = format
+		}
+		if _, ok := f.ExportLinks[mimeType]; ok {
+			return g.rem.Export(f.Id, mimeType)
+		}
+	}
+	var available []string
+	for mimeType := range f.ExportLinks {
+		available = append(available, mimeType)
+	}
+	return nil, fmt.Errorf("%s can't be exported to %s; it can be exported to %s",
+		g.opts.Path, strings.Join(formats, ","), strings.Join(available, ", "))
+}
+
+// Put uploads the content read from r to the remote file at Path,
+// creating it if it doesn't exist. The local copy is left untouched;
+// pull to update it.
+func (g *Commands) Put(r io.Reader) (err error) {
+	if g.opts.Path == "/" {
+		return ErrPathExists
+	}
+	var parent, existing *File
+	if parent, err = g.rem.FindByPath(path.Dir(g.opts.Path)); err != nil {
+		return
+	}
+	if !parent.IsDir {
+		return ErrParentNotDir
+	}
+	file := &File{Name: path.Base(g.opts.Path)}
+	switch existing, err = g.rem.FindByPath(g.opts.Path); {
+	case err == nil && existing.IsDir:
+		return fmt.Errorf("%s is a folder", g.opts.Path)
+	case err == nil:
+		file.Id = existing.Id
+	case err != ErrPathNotExists:
+		return
+	}
+	var uploaded *File
+	if uploaded, err = g.rem.Upsert(parent.Id, file, r, g.opts.KeepForever); err != nil {
+		return
+	}
+	if existing == nil {
+		g.record(cmdPut, OpAdd, g.opts.Path, uploaded.Id, nil)
+	} else {
+		g.record(cmdPut, OpMod, g.opts.Path, uploaded.Id, existing)
+	}
+	fmt.Fprintln(os.Stderr, "Uploaded", g.opts.Path)
+	return
+}
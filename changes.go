@@ -19,6 +19,7 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type dirList struct {
@@ -41,6 +42,9 @@ func (g *Commands) resolveChangeListRecv(
 	} else {
 		change = &Change{Path: p, Src: r, Dest: l}
 	}
+	if change.Dest != nil {
+		atomic.AddInt64(&g.numOfDestFiles, 1)
+	}
 	if change.Op() != OpNone {
 		cl = append(cl, change)
 	}
@@ -73,7 +77,13 @@ func (g *Commands) resolveChangeListRecv(
 	}
 
 	// TODO: limit the number of active tasks for children lookups
-	dirlist := merge(remoteChildren, localChildren)
+	var dirlist []*dirList
+	for _, l := range merge(remoteChildren, localChildren) {
+		childPath := path.Join(p, l.Name())
+		if !g.isIgnored(childPath) && g.isSparseIncluded(childPath) {
+			dirlist = append(dirlist, l)
+		}
+	}
 	var wg sync.WaitGroup
 	wg.Add(len(dirlist))
 	for _, l := range dirlist {
@@ -87,6 +97,26 @@ func (g *Commands) resolveChangeListRecv(
 	return cl, nil
 }
 
+// isIgnored reports whether the path matches one of the ignore
+// patterns. Patterns with a slash are matched against the context
+// relative path, with or without its leading slash, others against the
+// base name.
+func (g *Commands) isIgnored(p string) bool {
+	for _, pattern := range g.opts.Ignore {
+		name := path.Base(p)
+		if strings.Contains(pattern, "/") {
+			name = p
+			if !strings.HasPrefix(pattern, "/") {
+				name = strings.TrimPrefix(p, "/")
+			}
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func merge(remotes, locals []*File) (merged []*dirList) {
 	for _, r := range remotes {
 		list := &dirList{remote: r}
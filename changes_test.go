@@ -0,0 +1,41 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import "testing"
+
+func TestIsIgnored(t *testing.T) {
+	g := &Commands{opts: &Options{Ignore: []string{"*.tmp", "node_modules", "build/*.o", "/docs/draft*"}}}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/a.tmp", true},
+		{"/dir/b.tmp", true},
+		{"/a.txt", false},
+		{"/node_modules", true},
+		{"/web/node_modules", true},
+		{"/build/x.o", true},
+		{"/build/sub/x.o", false},
+		{"/src/build/x.o", false},
+		{"/docs/draft-1", true},
+		{"/old/docs/draft-1", false},
+	}
+	for _, tt := range tests {
+		if got := g.isIgnored(tt.path); got != tt.want {
+			t.Errorf("isIgnored(%s) = %t; want %t", tt.path, got, tt.want)
+		}
+	}
+}
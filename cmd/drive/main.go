@@ -16,10 +16,13 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/rakyll/command"
 	"github.com/rakyll/drive"
@@ -35,69 +38,299 @@ const (
 	descDiff      = "compares a local file with remote"
 	descPublish   = "publishes a file and prints its publicly available url"
 	descUnpublish = "revokes public access to a file"
+	descTrash     = "lists, restores or deletes trashed files"
+	descLog       = "lists the changes applied by past pushes and pulls"
+	descUndo      = "reverts the changes of the last push or pull"
+	descProfile   = "lists, adds or removes account profiles"
+	descDrives    = "lists the available shared drives"
+	descConfig    = "lists, gets or sets the settings of a context"
+	descSparse    = "lists, adds or removes the subtrees of a sparse checkout"
+	descWatch     = "pushes local changes as they happen and pulls remote changes periodically"
+	descDaemon    = "syncs several contexts in the background"
+	descStatus    = "shows or controls the state of the background daemon"
+	descLs        = "lists the remote files and their sync state"
+	descStat      = "shows the remote metadata and permissions of a file"
+	descFind      = "finds remote files by name, type, owner or modification time"
+	descMv        = "moves or renames a remote file and its local copy"
+	descCp        = "copies a remote file and its local copy"
+	descMkdir     = "creates a remote folder"
+	descRm        = "trashes or deletes a remote file and its local copy"
+	descShare     = "shares a remote file with a user, group or domain"
+	descUnshare   = "removes a grantee's access to a remote file"
+	descRevisions = "lists the revisions of a remote file"
+	descRevert    = "restores a revision of a remote file"
+	descCat       = "writes the content of a remote file to the standard output"
+	descPut       = "uploads the standard input to a remote file"
 )
 
 func main() {
 	command.On("init", descInit, &initCmd{}, []string{})
 	command.On("pull", descPull, &pullCmd{}, []string{})
 	command.On("push", descPush, &pushCmd{}, []string{})
+	command.On("ls", descLs, &lsCmd{}, []string{})
+	command.On("stat", descStat, &statCmd{}, []string{})
+	command.On("find", descFind, &findCmd{}, []string{})
+	command.On("mv", descMv, &mvCmd{}, []string{})
+	command.On("cp", descCp, &cpCmd{}, []string{})
+	command.On("mkdir", descMkdir, &mkdirCmd{}, []string{})
+	command.On("rm", descRm, &rmCmd{}, []string{})
+	command.On("share", descShare, &shareCmd{}, []string{})
+	command.On("unshare", descUnshare, &unshareCmd{}, []string{})
+	command.On("revisions", descRevisions, &revisionsCmd{}, []string{})
+	command.On("revert", descRevert, &revertCmd{}, []string{})
+	command.On("cat", descCat, &catCmd{}, []string{})
+	command.On("put", descPut, &putCmd{}, []string{})
 	command.On("diff", descDiff, &diffCmd{}, []string{})
 	command.On("pub", descPublish, &publishCmd{}, []string{})
 	command.On("unpub", descUnpublish, &unpublishCmd{}, []string{})
+	command.On("trash", descTrash, &trashCmd{}, []string{})
+	command.On("log", descLog, &logCmd{}, []string{})
+	command.On("undo", descUndo, &undoCmd{}, []string{})
+	command.On("profile", descProfile, &profileCmd{}, []string{})
+	command.On("drives", descDrives, &drivesCmd{}, []string{})
+	command.On("config", descConfig, &configCmd{}, []string{})
+	command.On("sparse", descSparse, &sparseCmd{}, []string{})
+	command.On("watch", descWatch, &watchCmd{}, []string{})
+	command.On("daemon", descDaemon, &daemonCmd{}, []string{})
+	command.On("status", descStatus, &statusCmd{}, []string{})
 	command.ParseAndRun()
 }
 
-type initCmd struct{}
+// authFlags are the flags of the commands that authorize an account.
+type authFlags struct {
+	isHeadless        *bool
+	serviceAccountKey *string
+	subject           *string
+	secretStore       *string
+	secretHelper      *string
+}
+
+func (f *authFlags) register(fs *flag.FlagSet) {
+	f.isHeadless = fs.Bool("headless", os.Getenv("SSH_CONNECTION") != "", "authorizes in a browser on another device and pastes the address it is redirected to")
+	f.serviceAccountKey = fs.String("service-account", "", "path of a service account JSON key to authenticate with")
+	f.subject = fs.String("subject", "", "email of the domain user the service account impersonates")
+	f.secretStore = fs.String("secret-store", "", "keeps the secrets in an encrypted \"file\" or the system \"keyring\"")
+	f.secretHelper = fs.String("secret-helper", "", "keeps the secrets with an external credential helper")
+}
+
+func (f *authFlags) options(profile string) *drive.Options {
+	return &drive.Options{
+		IsHeadless:        *f.isHeadless,
+		ServiceAccountKey: *f.serviceAccountKey,
+		Subject:           *f.subject,
+		SecretStore:       *f.secretStore,
+		SecretHelper:      *f.secretHelper,
+		Profile:           profile,
+	}
+}
+
+type initCmd struct {
+	authFlags
+	profile *string
+	root    *string
+	drive   *string
+}
 
 func (cmd *initCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.register(fs)
+	cmd.profile = fs.String("profile", "", "binds the context to the named profile, creating it if necessary")
+	cmd.root = fs.String("root", "", "maps the context to a remote folder: a /path in My Drive, shared-with-me/path or a folder id")
+	cmd.drive = fs.String("drive", "", "binds the context to the shared drive with the name or id")
 	return fs
 }
 
 func (cmd *initCmd) Run(args []string) {
-	exitWithError(drive.New(initContext(args), nil).Init())
+	opts := cmd.options(*cmd.profile)
+	opts.Root = *cmd.root
+	opts.Drive = *cmd.drive
+	exitWithError(drive.New(initContext(args), opts).Init())
+}
+
+type profileCmd struct {
+	authFlags
+}
+
+func (cmd *profileCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.register(fs)
+	return fs
+}
+
+func (cmd *profileCmd) Run(args []string) {
+	if len(args) < 1 {
+		exitWithError(errors.New("usage: gd profile list|add <name>|remove <name>"))
+	}
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+	g := drive.New(nil, cmd.options(name))
+	switch args[0] {
+	case "list":
+		exitWithError(g.ProfileList())
+	case "add":
+		exitWithError(g.ProfileAdd())
+	case "remove":
+		exitWithError(g.ProfileRemove())
+	default:
+		exitWithError(fmt.Errorf("unknown profile command: %s", args[0]))
+	}
+}
+
+// syncFlags are the flags of push and pull. Except for -i and
+// -allow-root-deletes, they override the context's settings only if
+// they are given on the command line.
+type syncFlags struct {
+	fs               *flag.FlagSet
+	isInteractive    *bool
+	allowRootDeletes *bool
+	wait             *bool
+}
+
+func (f *syncFlags) register(fs *flag.FlagSet, action string) {
+	f.fs = fs
+	fs.Bool("r", true, "performs the "+action+" action recursively")
+	fs.Bool("no-prompt", false, "shows no prompt before applying the "+action+" action")
+	fs.Bool("hidden", false, "allows syncing of hidden paths")
+	fs.Int("concurrency", 4, "number of files to transfer at once")
+	fs.String("ignore", "", "comma separated patterns of the paths to ignore")
+	fs.Int("max-deletes", 100, "aborts if more files would be deleted, 0 for no limit")
+	fs.Int("max-delete-percent", 0, "aborts if a larger percentage of files would be deleted, 0 for no limit")
+	f.isInteractive = fs.Bool("i", false, "asks for confirmation of each change")
+	f.allowRootDeletes = fs.Bool("allow-root-deletes", false, "allows deleting direct children of the context root")
+	f.wait = fs.Bool("wait", false, waitUsage)
+}
+
+func (f *syncFlags) options(context *config.Context, path string) *drive.Options {
+	s := loadSettings(context, f.fs)
+	return &drive.Options{
+		Path:             path,
+		IsRecursive:      s.Bool("recursive"),
+		IsNoPrompt:       s.Bool("no-prompt"),
+		Hidden:           s.Bool("hidden"),
+		Concurrency:      s.Int("concurrency"),
+		Ignore:           s.List("ignore"),
+		MaxDeletes:       s.Int("max-deletes"),
+		MaxDeletePercent: s.Int("max-delete-percent"),
+		TrashRetention:   s.Duration("trash-retention"),
+		IsInteractive:    *f.isInteractive,
+		AllowRootDeletes: *f.allowRootDeletes,
+	}
 }
 
 type pullCmd struct {
-	isRecursive *bool
-	isNoPrompt  *bool
+	syncFlags
+	rev *string
 }
 
 func (cmd *pullCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
-	cmd.isRecursive = fs.Bool("r", true, "performs the pull action recursively")
-	cmd.isNoPrompt = fs.Bool("no-prompt", false, "shows no prompt before applying the pull action")
+	cmd.register(fs, "pull")
+	cmd.rev = fs.String("rev", "", "pulls the revision of a file instead of its head revision")
 	return fs
 }
 
 func (cmd *pullCmd) Run(args []string) {
 	context, path := discoverContext(args)
-	exitWithError(drive.New(context, &drive.Options{
-		Path:        path,
-		IsRecursive: *cmd.isRecursive,
-		IsNoPrompt:  *cmd.isNoPrompt,
-	}).Pull())
+	g := drive.New(context, cmd.options(context, path))
+	fn := g.Pull
+	if *cmd.rev != "" {
+		fn = func() error { return g.PullRevision(*cmd.rev) }
+	}
+	exitWithError(withLock(context, "pull", *cmd.wait, fn))
 }
 
 type pushCmd struct {
-	hidden      *bool
-	isNoPrompt  *bool
-	isRecursive *bool
+	syncFlags
+	keepForever *bool
 }
 
 func (cmd *pushCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
-	cmd.isRecursive = fs.Bool("r", true, "performs the push action recursively")
-	cmd.isNoPrompt = fs.Bool("no-prompt", false, "shows no prompt before applying the push action")
-	cmd.hidden = fs.Bool("hidden", false, "allows syncing of hidden paths")
+	cmd.register(fs, "push")
+	cmd.keepForever = fs.Bool("keep-forever", false, "keeps the uploaded revisions forever")
 	return fs
 }
 
 func (cmd *pushCmd) Run(args []string) {
 	context, path := discoverContext(args)
+	opts := cmd.options(context, path)
+	opts.KeepForever = *cmd.keepForever
+	g := drive.New(context, opts)
+	exitWithError(withLock(context, "push", *cmd.wait, g.Push))
+}
+
+type catCmd struct {
+	fs  *flag.FlagSet
+	rev *string
+}
+
+func (cmd *catCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.fs = fs
+	fs.String("export", "docx,xlsx,pptx,svg", "comma separated formats to export native Google documents to, in order of preference")
+	cmd.rev = fs.String("rev", "", "writes the revision instead of the head revision")
+	return fs
+}
+
+func (cmd *catCmd) Run(args []string) {
+	if len(args) != 1 {
+		exitWithError(errors.New("usage: gd cat [-export <formats>] [-rev <id>] <path>"))
+	}
+	context, path := discoverContext(args)
+	formats := loadSettings(context, cmd.fs).List("export")
 	exitWithError(drive.New(context, &drive.Options{
-		Path:        path,
-		Hidden:      *cmd.hidden,
-		IsNoPrompt:  *cmd.isNoPrompt,
-		IsRecursive: *cmd.isRecursive,
-	}).Push())
+		Path: path,
+	}).Cat(os.Stdout, formats, *cmd.rev))
+}
+
+type putCmd struct {
+	keepForever *bool
+	wait        *bool
+}
+
+func (cmd *putCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.keepForever = fs.Bool("keep-forever", false, "keeps the uploaded revision forever")
+	cmd.wait = fs.Bool("wait", false, waitUsage)
+	return fs
+}
+
+func (cmd *putCmd) Run(args []string) {
+	if len(args) != 1 {
+		exitWithError(errors.New("usage: gd put <path> < file"))
+	}
+	context, path := discoverContext(args)
+	g := drive.New(context, &drive.Options{Path: path, KeepForever: *cmd.keepForever})
+	exitWithError(withLock(context, "put", *cmd.wait, func() error { return g.Put(os.Stdin) }))
+}
+
+type revisionsCmd struct{}
+
+func (cmd *revisionsCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	return fs
+}
+
+func (cmd *revisionsCmd) Run(args []string) {
+	context, path := discoverContext(args)
+	exitWithError(drive.New(context, &drive.Options{
+		Path: path,
+	}).Revisions())
+}
+
+type revertCmd struct {
+	rev  *string
+	wait *bool
+}
+
+func (cmd *revertCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.rev = fs.String("rev", "", "id of the revision to restore")
+	cmd.wait = fs.Bool("wait", false, waitUsage)
+	return fs
+}
+
+func (cmd *revertCmd) Run(args []string) {
+	if len(args) != 1 || *cmd.rev == "" {
+		exitWithError(errors.New("usage: gd revert -rev <id> <path>"))
+	}
+	context, path := discoverContext(args)
+	g := drive.New(context, &drive.Options{Path: path})
+	exitWithError(withLock(context, "revert", *cmd.wait, func() error { return g.Revert(*cmd.rev) }))
 }
 
 type diffCmd struct{}
@@ -113,29 +346,552 @@ func (cmd *diffCmd) Run(args []string) {
 	}).Diff())
 }
 
-type publishCmd struct{}
-type unpublishCmd struct{}
+type lsCmd struct {
+	isLong      *bool
+	isRecursive *bool
+	hidden      *bool
+	sortBy      *string
+	isReverse   *bool
+}
+
+func (cmd *lsCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.isLong = fs.Bool("l", false, "uses the long listing format")
+	cmd.isRecursive = fs.Bool("r", false, "lists the subfolders recursively")
+	cmd.hidden = fs.Bool("hidden", false, "lists hidden local paths")
+	cmd.sortBy = fs.String("sort", drive.SortByName, "sorts by name, size or time")
+	cmd.isReverse = fs.Bool("reverse", false, "reverses the sort order")
+	return fs
+}
+
+func (cmd *lsCmd) Run(args []string) {
+	context, path := discoverContext(args)
+	exitWithError(drive.New(context, &drive.Options{
+		Path:        path,
+		IsRecursive: *cmd.isRecursive,
+		Hidden:      *cmd.hidden,
+	}).List(*cmd.isLong, *cmd.sortBy, *cmd.isReverse))
+}
+
+type statCmd struct {
+	isJSON *bool
+}
+
+func (cmd *statCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.isJSON = fs.Bool("json", false, "prints the metadata as JSON")
+	return fs
+}
+
+func (cmd *statCmd) Run(args []string) {
+	context, path := discoverContext(args)
+	exitWithError(drive.New(context, &drive.Options{
+		Path: path,
+	}).Stat(*cmd.isJSON))
+}
+
+type findCmd struct {
+	query         drive.Query
+	modifiedAfter *string
+}
+
+func (cmd *findCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	fs.StringVar(&cmd.query.Name, "name", "", "finds the files whose name contains the string")
+	fs.StringVar(&cmd.query.Type, "type", "", "finds the files of the type: file, folder, document, spreadsheet, presentation, drawing or form")
+	fs.StringVar(&cmd.query.Owner, "owner", "", "finds the files owned by the email")
+	fs.StringVar(&cmd.query.Mime, "mime", "", "finds the files of the MIME type")
+	fs.BoolVar(&cmd.query.Starred, "starred", false, "finds the starred files")
+	fs.BoolVar(&cmd.query.Shared, "shared", false, "finds the files shared with others")
+	fs.StringVar(&cmd.query.Raw, "query", "", "finds the files matching the Drive query expression")
+	cmd.modifiedAfter = fs.String("modified-after", "", "finds the files modified after the date (2006-01-02 or RFC 3339) or duration ago (72h)")
+	return fs
+}
+
+func (cmd *findCmd) Run(args []string) {
+	if s := *cmd.modifiedAfter; s != "" {
+		var err error
+		if cmd.query.ModifiedAfter, err = parseTime(s); err != nil {
+			exitWithError(fmt.Errorf("invalid -modified-after: %s", s))
+		}
+	}
+	context, path := discoverContext(args)
+	settings, err := config.LoadSettings(context)
+	exitWithError(err)
+	exitWithError(drive.New(context, &drive.Options{
+		Path:   path,
+		Ignore: settings.List("ignore"),
+	}).Find(&cmd.query))
+}
+
+// parseTime parses a date, an RFC 3339 time or a duration before now.
+func parseTime(s string) (t time.Time, err error) {
+	if d, e := time.ParseDuration(s); e == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err = time.Parse(time.RFC3339, s); err == nil {
+		return
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+type mvCmd struct {
+	wait *bool
+}
+
+func (cmd *mvCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.wait = fs.Bool("wait", false, waitUsage)
+	return fs
+}
+
+func (cmd *mvCmd) Run(args []string) {
+	if len(args) != 2 {
+		exitWithError(errors.New("usage: gd mv <src> <dest>"))
+	}
+	context, src := discoverContext(args)
+	g := drive.New(context, &drive.Options{Path: src})
+	dest := contextPathOf(context, args[1])
+	exitWithError(withLock(context, "mv", *cmd.wait, func() error { return g.Move(dest) }))
+}
+
+type cpCmd struct {
+	wait *bool
+}
+
+func (cmd *cpCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.wait = fs.Bool("wait", false, waitUsage)
+	return fs
+}
+
+func (cmd *cpCmd) Run(args []string) {
+	if len(args) != 2 {
+		exitWithError(errors.New("usage: gd cp <src> <dest>"))
+	}
+	context, src := discoverContext(args)
+	g := drive.New(context, &drive.Options{Path: src})
+	dest := contextPathOf(context, args[1])
+	exitWithError(withLock(context, "cp", *cmd.wait, func() error { return g.Copy(dest) }))
+}
+
+type mkdirCmd struct {
+	isParents *bool
+	wait      *bool
+}
+
+func (cmd *mkdirCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.isParents = fs.Bool("p", false, "creates the missing parents and doesn't fail if the folder exists")
+	cmd.wait = fs.Bool("wait", false, waitUsage)
+	return fs
+}
+
+func (cmd *mkdirCmd) Run(args []string) {
+	if len(args) != 1 {
+		exitWithError(errors.New("usage: gd mkdir [-p] <path>"))
+	}
+	context, path := discoverContext(args)
+	g := drive.New(context, &drive.Options{Path: path})
+	exitWithError(withLock(context, "mkdir", *cmd.wait, func() error { return g.Mkdir(*cmd.isParents) }))
+}
+
+type rmCmd struct {
+	fs               *flag.FlagSet
+	isRecursive      *bool
+	isPermanent      *bool
+	allowRootDeletes *bool
+	wait             *bool
+}
+
+func (cmd *rmCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.fs = fs
+	cmd.isRecursive = fs.Bool("r", false, "removes folders and their contents")
+	cmd.isPermanent = fs.Bool("permanent", false, "deletes permanently instead of moving to the trash")
+	fs.Bool("no-prompt", false, "shows no prompt before removing")
+	fs.Int("max-deletes", 100, "aborts if more files would be removed, 0 for no limit")
+	cmd.allowRootDeletes = fs.Bool("allow-root-deletes", false, "allows removing direct children of the context root")
+	cmd.wait = fs.Bool("wait", false, waitUsage)
+	return fs
+}
+
+func (cmd *rmCmd) Run(args []string) {
+	if len(args) != 1 {
+		exitWithError(errors.New("usage: gd rm [-r] [-permanent] <path>"))
+	}
+	context, path := discoverContext(args)
+	s := loadSettings(context, cmd.fs)
+	g := drive.New(context, &drive.Options{
+		Path:             path,
+		IsRecursive:      *cmd.isRecursive,
+		IsNoPrompt:       s.Bool("no-prompt"),
+		MaxDeletes:       s.Int("max-deletes"),
+		AllowRootDeletes: *cmd.allowRootDeletes,
+	})
+	exitWithError(withLock(context, "rm", *cmd.wait, func() error { return g.Remove(*cmd.isPermanent) }))
+}
+
+type shareCmd struct {
+	grant       drive.Grant
+	expires     *string
+	isList      *bool
+	isRecursive *bool
+	wait        *bool
+}
+
+func (cmd *shareCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	fs.StringVar(&cmd.grant.Role, "role", "reader", "role to grant: reader, commenter, writer or owner")
+	fs.BoolVar(&cmd.grant.IsGroup, "group", false, "shares with the group of the email")
+	fs.BoolVar(&cmd.grant.Notify, "notify", true, "sends a notification email to the grantee")
+	fs.StringVar(&cmd.grant.Message, "message", "", "message to include in the notification email")
+	cmd.expires = fs.String("expires", "", "expires the access of a user or group at the date (2006-01-02 or RFC 3339) or after the duration (72h)")
+	cmd.isList = fs.Bool("list", false, "lists the current permissions")
+	cmd.isRecursive = fs.Bool("r", false, "applies to the descendants of a folder too")
+	cmd.wait = fs.Bool("wait", false, waitUsage)
+	return fs
+}
+
+func (cmd *shareCmd) Run(args []string) {
+	if *cmd.isList {
+		context, path := discoverContext(args)
+		exitWithError(drive.New(context, &drive.Options{Path: path}).ListPermissions())
+		return
+	}
+	if len(args) != 2 {
+		exitWithError(errors.New("usage: gd share [-role <role>] [-expires <time>] [-r] <path> <email|domain|anyone>\n       gd share -list <path>"))
+	}
+	if s := *cmd.expires; s != "" {
+		var t time.Time
+		if d, err := time.ParseDuration(s); err == nil {
+			t = time.Now().Add(d)
+		} else if t, err = parseTime(s); err != nil {
+			exitWithError(fmt.Errorf("invalid -expires: %s", s))
+		}
+		cmd.grant.Expiration = &t
+	}
+	context, path := discoverContext(args)
+	cmd.grant.Grantee = args[1]
+	g := drive.New(context, &drive.Options{Path: path, IsRecursive: *cmd.isRecursive})
+	exitWithError(withLock(context, "share", *cmd.wait, func() error { return g.Share(&cmd.grant) }))
+}
+
+type unshareCmd struct {
+	isRecursive *bool
+	wait        *bool
+}
+
+func (cmd *unshareCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.isRecursive = fs.Bool("r", false, "applies to the descendants of a folder too")
+	cmd.wait = fs.Bool("wait", false, waitUsage)
+	return fs
+}
+
+func (cmd *unshareCmd) Run(args []string) {
+	if len(args) != 2 {
+		exitWithError(errors.New("usage: gd unshare [-r] <path> <email|domain|anyone>"))
+	}
+	context, path := discoverContext(args)
+	g := drive.New(context, &drive.Options{Path: path, IsRecursive: *cmd.isRecursive})
+	exitWithError(withLock(context, "unshare", *cmd.wait, func() error { return g.Unshare(args[1]) }))
+}
+
+type publishCmd struct {
+	pub  drive.Publication
+	wait *bool
+}
+type unpublishCmd struct {
+	wait *bool
+}
 
 func (cmd *unpublishCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.wait = fs.Bool("wait", false, waitUsage)
 	return fs
 }
 
 func (cmd *unpublishCmd) Run(args []string) {
 	context, path := discoverContext(args)
-	exitWithError(drive.New(context, &drive.Options{
+	g := drive.New(context, &drive.Options{
 		Path: path,
-	}).Unpublish())
+	})
+	exitWithError(withLock(context, "unpub", *cmd.wait, g.Unpublish))
 }
 
 func (cmd *publishCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	fs.BoolVar(&cmd.pub.IsPublic, "public", false, "makes the file public on the web instead of accessible to anyone with the link")
+	fs.BoolVar(&cmd.pub.AllowDownload, "download", true, "allows the viewers to download, print and copy the file")
+	cmd.wait = fs.Bool("wait", false, waitUsage)
 	return fs
 }
 
 func (cmd *publishCmd) Run(args []string) {
 	context, path := discoverContext(args)
-	exitWithError(drive.New(context, &drive.Options{
+	g := drive.New(context, &drive.Options{
 		Path: path,
-	}).Publish())
+	})
+	exitWithError(withLock(context, "pub", *cmd.wait, func() error { return g.Publish(&cmd.pub) }))
+}
+
+type trashCmd struct {
+	fs         *flag.FlagSet
+	isLocal    *bool
+	isNoPrompt *bool
+	wait       *bool
+}
+
+func (cmd *trashCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.isLocal = fs.Bool("local", false, "operates on the local trash of the files deleted by pull instead of the Google Drive trash")
+	cmd.isNoPrompt = fs.Bool("no-prompt", false, "shows no prompt before permanently deleting")
+	cmd.fs = fs
+	fs.Duration("older-than", drive.DefaultLocalTrashRetention, "purges the local entries older than the duration; defaults to the trash-retention setting")
+	cmd.wait = fs.Bool("wait", false, waitUsage)
+	return fs
+}
+
+func (cmd *trashCmd) Run(args []string) {
+	if len(args) < 1 {
+		exitWithError(errors.New("usage: gd trash list|restore <path>|empty [<path>]\n       gd trash -local list|restore <entry>[/<path>]|purge"))
+	}
+	context, _ := discoverContext(nil)
+	g := drive.New(context, &drive.Options{
+		Path:       strings.Join(args[1:], "/"),
+		IsNoPrompt: *cmd.isNoPrompt,
+	})
+	var fn func() error
+	switch sub := args[0]; {
+	case sub == "list" && *cmd.isLocal:
+		exitWithError(g.LocalTrashList())
+	case sub == "list":
+		exitWithError(g.RemoteTrashList())
+	case sub == "restore" && *cmd.isLocal:
+		fn = g.LocalTrashRestore
+	case sub == "restore":
+		fn = g.RemoteTrashRestore
+	case sub == "purge" && *cmd.isLocal:
+		retention := loadSettings(context, cmd.fs).Duration("trash-retention")
+		fn = func() error { return g.LocalTrashPurge(retention) }
+	case sub == "empty" && !*cmd.isLocal:
+		fn = g.RemoteTrashEmpty
+	default:
+		exitWithError(fmt.Errorf("unknown trash command: %s", sub))
+	}
+	if fn != nil {
+		exitWithError(withLock(context, "trash "+args[0], *cmd.wait, fn))
+	}
+}
+
+type logCmd struct{}
+
+func (cmd *logCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	return fs
+}
+
+func (cmd *logCmd) Run(args []string) {
+	context, _ := discoverContext(args)
+	exitWithError(drive.New(context, &drive.Options{}).Log())
+}
+
+type undoCmd struct {
+	isNoPrompt *bool
+	wait       *bool
+}
+
+func (cmd *undoCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.isNoPrompt = fs.Bool("no-prompt", false, "shows no prompt before undoing")
+	cmd.wait = fs.Bool("wait", false, waitUsage)
+	return fs
+}
+
+func (cmd *undoCmd) Run(args []string) {
+	context, _ := discoverContext(args)
+	g := drive.New(context, &drive.Options{
+		IsNoPrompt: *cmd.isNoPrompt,
+	})
+	exitWithError(withLock(context, "undo", *cmd.wait, g.Undo))
+}
+
+type drivesCmd struct{}
+
+func (cmd *drivesCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	return fs
+}
+
+func (cmd *drivesCmd) Run(args []string) {
+	context, _ := discoverContext(args)
+	exitWithError(drive.New(context, &drive.Options{}).Drives())
+}
+
+type sparseCmd struct {
+	syncFlags
+}
+
+func (cmd *sparseCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.register(fs, "pull")
+	return fs
+}
+
+func (cmd *sparseCmd) Run(args []string) {
+	if len(args) < 1 || (args[0] != "list" && len(args) != 2) {
+		exitWithError(errors.New("usage: gd sparse list|add <pattern>|remove <pattern>"))
+	}
+	context, _ := discoverContext(nil)
+	pattern := ""
+	if len(args) > 1 {
+		pattern = args[1]
+	}
+	g := drive.New(context, cmd.options(context, pattern))
+	switch args[0] {
+	case "list":
+		exitWithError(g.SparseList())
+	case "add":
+		exitWithError(withLock(context, "sparse add", *cmd.wait, g.SparseAdd))
+	case "remove":
+		exitWithError(withLock(context, "sparse remove", *cmd.wait, g.SparseRemove))
+	default:
+		exitWithError(fmt.Errorf("unknown sparse command: %s", args[0]))
+	}
+}
+
+type watchCmd struct {
+	syncFlags
+	debounce     *time.Duration
+	pollInterval *time.Duration
+}
+
+func (cmd *watchCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.register(fs, "push")
+	cmd.debounce = fs.Duration("debounce", drive.DefaultWatchDebounce, "waits for local changes to settle for the duration before pushing")
+	cmd.pollInterval = fs.Duration("poll", drive.DefaultWatchPollInterval, "pulls remote changes at the interval")
+	return fs
+}
+
+func (cmd *watchCmd) Run(args []string) {
+	context, path := discoverContext(args)
+	exitWithError(drive.New(context, cmd.options(context, path)).Watch(*cmd.debounce, *cmd.pollInterval))
+}
+
+type daemonCmd struct {
+	syncFlags
+	socket   *string
+	interval *time.Duration
+}
+
+func (cmd *daemonCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.register(fs, "sync")
+	cmd.socket = fs.String("socket", drive.DefaultDaemonSocket(), "path of the control socket")
+	cmd.interval = fs.Duration("interval", drive.DefaultDaemonInterval, "syncs each context at the interval")
+	return fs
+}
+
+func (cmd *daemonCmd) Run(args []string) {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	var contexts []*config.Context
+	var opts []*drive.Options
+	for _, arg := range args {
+		context, err := config.Discover(getContextPath([]string{arg}))
+		exitWithError(err)
+		contexts = append(contexts, context)
+		opts = append(opts, cmd.options(context, ""))
+	}
+	exitWithError(drive.RunDaemon(contexts, opts, *cmd.socket, *cmd.interval))
+}
+
+type statusCmd struct {
+	socket     *string
+	showErrors *bool
+}
+
+func (cmd *statusCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.socket = fs.String("socket", drive.DefaultDaemonSocket(), "path of the daemon's control socket")
+	cmd.showErrors = fs.Bool("errors", false, "lists the recent errors of each context")
+	return fs
+}
+
+func (cmd *statusCmd) Run(args []string) {
+	methods := map[string]string{
+		"":       "Status",
+		"pause":  "Pause",
+		"resume": "Resume",
+		"sync":   "Sync",
+	}
+	action, context := "", ""
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if len(args) > 1 {
+		abs, err := filepath.Abs(args[1])
+		exitWithError(err)
+		context = abs
+	}
+	method, ok := methods[action]
+	if !ok {
+		exitWithError(errors.New("usage: gd status [pause|resume|sync [<context>]]"))
+	}
+	exitWithError(drive.DaemonControl(*cmd.socket, method, context, *cmd.showErrors))
+}
+
+type configCmd struct {
+	isUser *bool
+}
+
+func (cmd *configCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.isUser = fs.Bool("user", false, "changes the user's settings instead of the context's")
+	return fs
+}
+
+func (cmd *configCmd) Run(args []string) {
+	if len(args) < 1 {
+		exitWithError(errors.New("usage: gd config list|get <key>|set <key> <value>|unset <key>"))
+	}
+	// settings can be listed and changed outside of a context
+	context, _ := config.Discover(getContextPath(nil))
+	g := drive.New(context, &drive.Options{})
+	var err error
+	switch {
+	case args[0] == "list":
+		err = g.ConfigList()
+	case args[0] == "get" && len(args) == 2:
+		err = g.ConfigGet(args[1])
+	case args[0] == "set" && len(args) == 3:
+		err = g.ConfigSet(args[1], args[2], *cmd.isUser)
+	case args[0] == "unset" && len(args) == 2:
+		err = g.ConfigSet(args[1], "", *cmd.isUser)
+	default:
+		err = fmt.Errorf("invalid config command: %s", strings.Join(args, " "))
+	}
+	exitWithError(err)
+}
+
+// flagSettings maps the flags whose names differ from the settings
+// they override.
+var flagSettings = map[string]string{
+	"r":          "recursive",
+	"older-than": "trash-retention",
+}
+
+// loadSettings loads the settings of the context and overrides them
+// with the flags that are given on the command line.
+func loadSettings(context *config.Context, fs *flag.FlagSet) *config.Settings {
+	s, err := config.LoadSettings(context)
+	exitWithError(err)
+	fs.Visit(func(f *flag.Flag) {
+		key, ok := flagSettings[f.Name]
+		if !ok {
+			key = f.Name
+		}
+		if config.IsSetting(key) {
+			exitWithError(s.Set(key, f.Value.String(), config.OriginFlag))
+		}
+	})
+	return s
+}
+
+const waitUsage = "waits for other gd commands in the context to finish instead of failing"
+
+// withLock runs fn while holding the context's lock. Every command that
+// changes the context or its remote should run within the lock.
+func withLock(context *config.Context, command string, wait bool, fn func() error) error {
+	l, err := context.Lock(command, wait)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return fn()
 }
 
 func initContext(args []string) *config.Context {
@@ -148,18 +904,38 @@ func initContext(args []string) *config.Context {
 func discoverContext(args []string) (*config.Context, string) {
 	var err error
 	context, err = config.Discover(getContextPath(args))
+	if err != nil && len(args) > 0 && strings.HasPrefix(args[0], "/") {
+		// a context relative path, such as one printed by find
+		context, err = config.Discover(getContextPath(nil))
+	}
 	exitWithError(err)
 	relPath := ""
 	if len(args) > 0 {
-		relPath, err = filepath.Rel(context.AbsPath, args[0])
+		relPath = contextPathOf(context, args[0])
 	}
-	exitWithError(err)
 	return context, relPath
 }
 
+// contextPathOf returns the path of the local path relative to the
+// context. A /path outside of the context is taken to be relative to
+// the context already.
+func contextPathOf(context *config.Context, localPath string) string {
+	absPath, err := filepath.Abs(localPath)
+	exitWithError(err)
+	relPath, err := filepath.Rel(context.AbsPath, absPath)
+	exitWithError(err)
+	if relPath == ".." || strings.HasPrefix(relPath, "../") {
+		if strings.HasPrefix(localPath, "/") {
+			return localPath
+		}
+		exitWithError(fmt.Errorf("%s is outside of the context", localPath))
+	}
+	return filepath.ToSlash(relPath)
+}
+
 func getContextPath(args []string) (contextPath string) {
 	if len(args) > 0 {
-		contextPath = args[0]
+		contextPath, _ = filepath.Abs(args[0])
 	}
 	if contextPath == "" {
 		contextPath, _ = os.Getwd()
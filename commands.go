@@ -17,6 +17,8 @@ package drive
 import (
 	"errors"
 	"path"
+	"sync"
+	"time"
 
 	"github.com/cheggaaa/pb"
 	"github.com/rakyll/drive/config"
@@ -31,8 +33,45 @@ type Options struct {
 	IsNoPrompt  bool
 	IsRecursive bool
 	IsForce     bool
+	// IsInteractive prompts for each change before applying it
+	IsInteractive bool
 	// Hidden discovers hidden paths if set
 	Hidden bool
+	// Concurrency is the number of files transferred at once
+	Concurrency int
+	// Ignore are the patterns of the paths that are never synced
+	Ignore []string
+	// MaxDeletes is the maximum number of deletions in a run, 0 for no limit
+	MaxDeletes int
+	// MaxDeletePercent is the maximum percentage of the destination
+	// files that can be deleted in a run, 0 for no limit
+	MaxDeletePercent int
+	// TrashRetention is how long pull keeps the local trash entries
+	// before purging them, DefaultLocalTrashRetention if 0
+	TrashRetention time.Duration
+	// AllowRootDeletes allows deleting direct children of the context root
+	AllowRootDeletes bool
+	// IsHeadless authorizes by pasting the redirect URL instead of with
+	// a local redirect listener
+	IsHeadless bool
+	// ServiceAccountKey is the path of a service account's JSON key to
+	// authenticate with instead of a user account
+	ServiceAccountKey string
+	// Subject is the user to impersonate with the service account
+	Subject string
+	// SecretStore is where init keeps the secrets; empty for the
+	// credentials file
+	SecretStore string
+	// SecretHelper is the credential helper of the helper secret store
+	SecretHelper string
+	// Profile is the name of the profile to create or bind to
+	Profile string
+	// Root is the remote folder path or id to map the context to
+	Root string
+	// Drive is the name or id of the shared drive to bind the context to
+	Drive string
+	// KeepForever keeps the revisions uploaded by push forever
+	KeepForever bool
 }
 
 type Commands struct {
@@ -41,6 +80,14 @@ type Commands struct {
 	opts    *Options
 
 	progress *pb.ProgressBar
+	// runId identifies this run in the journal and names its
+	// local trash entry
+	runId     string
+	journalMu sync.Mutex
+	// numOfDestFiles counts the destination files seen while resolving
+	numOfDestFiles int64
+	// sparse are the patterns of the checked out subtrees, if any
+	sparse []string
 }
 
 func New(context *config.Context, opts *Options) *Commands {
@@ -56,9 +103,36 @@ func New(context *config.Context, opts *Options) *Commands {
 		context: context,
 		rem:     r,
 		opts:    opts,
+		runId:   newRunId(),
 	}
 }
 
+// newRun starts a new run on the same Commands, so that each push or
+// pull of a long running command is journaled and trashed separately.
+func (g *Commands) newRun() {
+	g.runId = newRunId()
+	g.numOfDestFiles = 0
+}
+
+var (
+	runIdMu   sync.Mutex
+	lastRunId string
+)
+
+// newRunId returns a run id that is unique within the process, even for
+// runs started within the same microsecond.
+func newRunId() string {
+	runIdMu.Lock()
+	defer runIdMu.Unlock()
+	id := time.Now().Format(runIdLayout)
+	for id <= lastRunId {
+		time.Sleep(time.Microsecond)
+		id = time.Now().Format(runIdLayout)
+	}
+	lastRunId = id
+	return id
+}
+
 func (g *Commands) taskStart(numOfTasks int) {
 	if numOfTasks > 0 {
 		g.progress = pb.StartNew(numOfTasks)
@@ -0,0 +1,40 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRunIdIsUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := newRunId()
+		if seen[id] {
+			t.Fatalf("run id %s was returned twice", id)
+		}
+		seen[id] = true
+		if _, err := time.ParseInLocation(trashStampLayout, id, time.Local); err != nil {
+			t.Fatalf("run id %s doesn't parse: %v", id, err)
+		}
+	}
+}
+
+func TestTrashStampParsesOldEntries(t *testing.T) {
+	if _, err := time.ParseInLocation(trashStampLayout, "20140102T150405", time.Local); err != nil {
+		t.Errorf("entry without microseconds doesn't parse: %v", err)
+	}
+}
@@ -22,36 +22,199 @@ import (
 	"path"
 )
 
+const (
+	// CredentialsUser authenticates with a user's OAuth 2.0 refresh
+	// token, obtained by gd init.
+	CredentialsUser = "user"
+
+	// CredentialsServiceAccount authenticates with a service account's
+	// JSON key, optionally impersonating a user of the domain.
+	CredentialsServiceAccount = "service_account"
+)
+
+// Environment variables that override the credentials of a context.
+// If GD_SERVICE_ACCOUNT_KEY is set, the context authenticates as the
+// service account and doesn't need a credentials file.
+const (
+	EnvServiceAccountKey = "GD_SERVICE_ACCOUNT_KEY"
+	EnvSubject           = "GD_SUBJECT"
+)
+
+// Credentials are what a context authenticates with. They are kept in
+// the context's credentials file or in a named profile that can be
+// shared by several contexts.
+type Credentials struct {
+	// Type is the type of the credentials, user if empty.
+	Type         string `json:"type,omitempty"`
+	ClientId     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// ServiceAccountKey is the path of a service account's JSON key.
+	ServiceAccountKey string `json:"service_account_key,omitempty"`
+	// Subject is the email of the user a service account impersonates.
+	Subject string `json:"subject,omitempty"`
+	// SecretStore is where the client secret and the refresh token are
+	// kept; if empty, they are kept in the credentials file.
+	SecretStore  string `json:"secret_store,omitempty"`
+	SecretHelper string `json:"secret_helper,omitempty"`
+	// SecretRef is the reference of the secrets in the secret store.
+	SecretRef string `json:"secret_ref,omitempty"`
+
+	secretsLoaded bool
+}
+
+// CredentialsType returns the type of the credentials.
+func (c *Credentials) CredentialsType() string {
+	if c.Type == "" {
+		return CredentialsUser
+	}
+	return c.Type
+}
+
+// LoadSecrets reads the client secret and the refresh token from the
+// secret store, if one is configured. The store is only consulted the
+// first time, so that it doesn't prompt unless the secrets are needed.
+// Credentials without a reference haven't been stored yet and keep the
+// secrets they hold.
+func (c *Credentials) LoadSecrets() error {
+	if c.secretsLoaded || c.SecretRef == "" {
+		return nil
+	}
+	store, err := NewSecretStore(c)
+	if err != nil || store == nil {
+		return err
+	}
+	secrets, err := store.Get(c.SecretRef)
+	if err != nil {
+		return err
+	}
+	c.ClientSecret = secrets.ClientSecret
+	c.RefreshToken = secrets.RefreshToken
+	c.secretsLoaded = true
+	return nil
+}
+
+func (c *Credentials) readEnv() {
+	if key := os.Getenv(EnvServiceAccountKey); key != "" {
+		c.Type = CredentialsServiceAccount
+		c.ServiceAccountKey = key
+	}
+	if subject := os.Getenv(EnvSubject); subject != "" {
+		c.Subject = subject
+	}
+}
+
+// writeSecrets puts the secrets into the secret store, if one is
+// configured, under ref unless they already have a reference. It returns
+// a copy of the credentials that is safe to write to a file.
+func (c *Credentials) writeSecrets(ref string) (saved *Credentials, err error) {
+	var store SecretStore
+	if store, err = NewSecretStore(c); err != nil || store == nil {
+		return c, err
+	}
+	if c.SecretRef == "" {
+		c.SecretRef = ref
+	}
+	secrets := &Secrets{ClientSecret: c.ClientSecret, RefreshToken: c.RefreshToken}
+	if err = store.Put(c.SecretRef, secrets); err != nil {
+		return
+	}
+	copied := *c
+	copied.ClientSecret, copied.RefreshToken = "", ""
+	return &copied, nil
+}
+
 type Context struct {
-	ClientId     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
-	RefreshToken string `json:"refresh_token"`
-	AbsPath      string `json:"-"`
+	Credentials
+	// Profile is the name of the profile the context takes its
+	// credentials from, if any.
+	Profile string `json:"profile,omitempty"`
+	// RootId is the id of the remote folder the context is mapped to,
+	// My Drive's root folder if empty.
+	RootId string `json:"root_id,omitempty"`
+	// DriveId is the id of the shared drive the context is bound to, if
+	// any. Unless RootId is set, the context is mapped to its root.
+	DriveId string `json:"drive_id,omitempty"`
+	AbsPath string `json:"-"`
 }
 
 func (c *Context) AbsPathOf(fileOrDirPath string) string {
 	return path.Join(c.AbsPath, fileOrDirPath)
 }
 
+// TrashPath returns the path of the local trash, where the files
+// deleted by pull are kept until they are purged.
+func (c *Context) TrashPath() string {
+	return path.Join(gdPath(c.AbsPath), "trash")
+}
+
+// SettingsPath returns the path of the context's config file.
+func (c *Context) SettingsPath() string {
+	return path.Join(gdPath(c.AbsPath), "config")
+}
+
+// JournalPath returns the path of the journal of the changes applied
+// by push and pull.
+func (c *Context) JournalPath() string {
+	return path.Join(gdPath(c.AbsPath), "journal")
+}
+
+// IndexPath returns the path of the index of the files as they were
+// when they were last synced by the daemon.
+func (c *Context) IndexPath() string {
+	return path.Join(gdPath(c.AbsPath), "index")
+}
+
 func (c *Context) Read() (err error) {
 	var data []byte
-	if data, err = ioutil.ReadFile(credentialsPath(c.AbsPath)); err != nil {
+	data, err = ioutil.ReadFile(credentialsPath(c.AbsPath))
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(data, c); err != nil {
+			return
+		}
+	case !os.IsNotExist(err) || os.Getenv(EnvServiceAccountKey) == "":
+		return
+	default:
+		err = nil
+	}
+	if c.Profile != "" {
+		var creds *Credentials
+		if creds, err = ReadProfile(c.Profile); err != nil {
+			return
+		}
+		c.Credentials = *creds
 		return
 	}
-	err = json.Unmarshal(data, c)
+	c.readEnv()
 	return
 }
 
+// Write saves the context's credentials. If a secret store is
+// configured, the secrets are put into the store and only their
+// reference is written to the credentials file. A context that uses a
+// profile only saves the profile's name.
 func (c *Context) Write() (err error) {
+	saved := &Context{Profile: c.Profile, RootId: c.RootId, DriveId: c.DriveId}
+	if c.Profile == "" {
+		var creds *Credentials
+		if creds, err = c.writeSecrets(c.AbsPath); err != nil {
+			return
+		}
+		saved.Credentials = *creds
+	}
 	var data []byte
-	if data, err = json.Marshal(c); err != nil {
+	if data, err = json.Marshal(saved); err != nil {
 		return
 	}
 	return ioutil.WriteFile(credentialsPath(c.AbsPath), data, 0600)
 }
 
 // Discovers the gd directory, if no gd directory or credentials
-// could be found for the path, returns ErrNoContext.
+// could be found for the path, returns ErrNoContext. If there is no gd
+// directory but GD_SERVICE_ACCOUNT_KEY is set, the working directory
+// becomes the context and takes its credentials from the environment.
+// Its gd directory isn't made until the context keeps something in it.
 func Discover(currentAbsPath string) (context *Context, err error) {
 	p := currentAbsPath
 	found := false
@@ -69,7 +232,12 @@ func Discover(currentAbsPath string) (context *Context, err error) {
 	}
 
 	if !found {
-		return nil, errors.New("no gd context is found; use gd init")
+		if os.Getenv(EnvServiceAccountKey) == "" {
+			return nil, errors.New("no gd context is found; use gd init")
+		}
+		if p, err = os.Getwd(); err != nil {
+			return
+		}
 	}
 	context = &Context{AbsPath: p}
 	err = context.Read()
@@ -0,0 +1,67 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDiscoverFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv(EnvServiceAccountKey, os.Getenv(EnvServiceAccountKey))
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	// the context is made in the working directory, never at the path
+	// that is looked up
+	missing := dir + "/missing/dir"
+
+	os.Setenv(EnvServiceAccountKey, "")
+	if _, err = Discover(missing); err == nil {
+		t.Fatalf("Discover(%s) without a context or %s succeeded", missing, EnvServiceAccountKey)
+	}
+
+	os.Setenv(EnvServiceAccountKey, "/keys/sa.json")
+	var c *Context
+	if c, err = Discover(missing); err != nil {
+		t.Fatalf("Discover(%s) = %v", missing, err)
+	}
+	if wd, _ := os.Getwd(); c.AbsPath != wd || c.CredentialsType() != CredentialsServiceAccount || c.ServiceAccountKey != "/keys/sa.json" {
+		t.Errorf("Discover(%s) = %+v; want a service account context at %s", missing, c, dir)
+	}
+	if _, err := os.Stat(gdPath(dir)); !os.IsNotExist(err) {
+		t.Errorf("Discover(%s) created %s", missing, gdPath(dir))
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("Discover(%s) created the path", missing)
+	}
+	// until the lock needs it
+	l, err := c.Lock("push", false)
+	if err != nil {
+		t.Fatalf("Lock() = %v", err)
+	}
+	l.Unlock()
+	if info, err := os.Stat(gdPath(dir)); err != nil || !info.IsDir() {
+		t.Errorf("Lock() didn't create %s", gdPath(dir))
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+const (
+	lockPollInterval = time.Second
+
+	// staleGuardTimeout is how old a takeover guard has to be before it
+	// is considered abandoned by a crashed process.
+	staleGuardTimeout = 10 * time.Second
+)
+
+// LockInfo describes the holder of a context's lock.
+type LockInfo struct {
+	Pid     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Command string    `json:"command"`
+	Time    time.Time `json:"time"`
+}
+
+// ErrLocked is returned if the context is locked by another process.
+type ErrLocked struct {
+	Holder *LockInfo
+}
+
+func (e *ErrLocked) Error() string {
+	return fmt.Sprintf("context is locked by gd %s (pid %d on %s) since %s; use -wait to wait for it",
+		e.Holder.Command, e.Holder.Pid, e.Holder.Host, e.Holder.Time.Format(time.RFC3339))
+}
+
+// Lock is an advisory lock on a context, held by a single mutating
+// command at a time.
+type Lock struct {
+	path string
+}
+
+// Lock acquires the context's lock for the command. If the lock is held
+// by another process, it returns an *ErrLocked, or blocks until the lock
+// is released if wait is set. Locks left behind by processes that are no
+// longer running on this host are taken over. The lock is written to a
+// temporary file and linked into place, so that it is never seen empty.
+func (c *Context) Lock(command string, wait bool) (*Lock, error) {
+	l := &Lock{path: path.Join(gdPath(c.AbsPath), "lock")}
+	host, _ := os.Hostname()
+	info := &LockInfo{Pid: os.Getpid(), Host: host, Command: command, Time: time.Now()}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(gdPath(c.AbsPath), 0755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := writeTemp(l.path, data)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	isWaiting := false
+	for {
+		err := os.Link(tmp, l.path)
+		if err == nil {
+			return l, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		held, holder, err := readLockInfo(l.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if holder.Host == host && !processExists(holder.Pid) {
+			// stale; the holder has exited without releasing the lock
+			var ok bool
+			if ok, err = l.takeOver(held, data); err != nil {
+				return nil, err
+			}
+			if ok {
+				return l, nil
+			}
+			time.Sleep(lockPollInterval / 10)
+			continue
+		}
+		if !wait {
+			return nil, &ErrLocked{Holder: holder}
+		}
+		if !isWaiting {
+			fmt.Printf("Waiting for gd %s (pid %d on %s) to release the lock...\n", holder.Command, holder.Pid, holder.Host)
+			isWaiting = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	return os.Remove(l.path)
+}
+
+// takeOver replaces the stale lock, whose content is stale, with data.
+// Only one process at a time may take over, guarded by a second lock
+// file. The new lock is written to a temporary file and renamed over the
+// stale one, so that the lock file never disappears, and it is read
+// back to confirm the lock is ours. It returns false if another process
+// is taking over or has already taken over the lock.
+func (l *Lock) takeOver(stale, data []byte) (ok bool, err error) {
+	guard := l.path + ".takeover"
+	var f *os.File
+	if f, err = os.OpenFile(guard, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err != nil {
+		if !os.IsExist(err) {
+			return
+		}
+		if info, e := os.Stat(guard); e == nil && time.Since(info.ModTime()) > staleGuardTimeout {
+			os.Remove(guard)
+		}
+		return false, nil
+	}
+	f.Close()
+	defer os.Remove(guard)
+
+	var current []byte
+	if current, err = ioutil.ReadFile(l.path); err != nil || !bytes.Equal(current, stale) {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var tmp string
+	if tmp, err = writeTemp(l.path, data); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, l.path); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	if current, err = ioutil.ReadFile(l.path); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	return bytes.Equal(current, data), nil
+}
+
+// writeTemp writes data to a new temporary file next to p.
+func writeTemp(p string, data []byte) (name string, err error) {
+	var f *os.File
+	if f, err = ioutil.TempFile(path.Dir(p), path.Base(p)+"."); err != nil {
+		return
+	}
+	name = f.Name()
+	_, err = f.Write(data)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(name)
+	}
+	return
+}
+
+func readLockInfo(p string) (data []byte, info *LockInfo, err error) {
+	if data, err = ioutil.ReadFile(p); err != nil {
+		return
+	}
+	info = &LockInfo{}
+	if err = json.Unmarshal(data, info); err != nil {
+		err = fmt.Errorf("invalid lock file %s: %v", p, err)
+	}
+	return
+}
@@ -0,0 +1,138 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a pid that is not running
+const deadPid = 1<<22 + 1
+
+func newTestContext(t *testing.T) *Context {
+	dir, err := ioutil.TempDir("", "gd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(gdPath(dir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Context{AbsPath: dir}
+}
+
+func writeLock(t *testing.T, c *Context, info *LockInfo) {
+	data, _ := json.Marshal(info)
+	if err := ioutil.WriteFile(path.Join(gdPath(c.AbsPath), "lock"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	host, _ := os.Hostname()
+	tests := []struct {
+		desc   string
+		holder *LockInfo
+		locked bool
+	}{
+		{"unlocked", nil, false},
+		{"held by a running process", &LockInfo{Pid: os.Getpid(), Host: host, Command: "push"}, true},
+		{"held on another host", &LockInfo{Pid: deadPid, Host: host + ".other", Command: "push"}, true},
+		{"stale", &LockInfo{Pid: deadPid, Host: host, Command: "push"}, false},
+	}
+	for _, tt := range tests {
+		c := newTestContext(t)
+		if tt.holder != nil {
+			writeLock(t, c, tt.holder)
+		}
+		l, err := c.Lock("pull", false)
+		if _, isLocked := err.(*ErrLocked); isLocked != tt.locked || (!tt.locked && err != nil) {
+			t.Errorf("%s: Lock() = %v; want locked %t", tt.desc, err, tt.locked)
+		}
+		if l != nil {
+			if err = l.Unlock(); err != nil {
+				t.Errorf("%s: Unlock() = %v", tt.desc, err)
+			}
+		}
+		os.RemoveAll(c.AbsPath)
+	}
+}
+
+func TestLockTakeOverIsExclusive(t *testing.T) {
+	c := newTestContext(t)
+	defer os.RemoveAll(c.AbsPath)
+	host, _ := os.Hostname()
+	writeLock(t, c, &LockInfo{Pid: deadPid, Host: host, Command: "push", Time: time.Now()})
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		winners int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Lock("pull", false); err == nil {
+				mu.Lock()
+				winners++
+				mu.Unlock()
+			} else if _, ok := err.(*ErrLocked); !ok {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if winners != 1 {
+		t.Errorf("%d processes took over the stale lock; want 1", winners)
+	}
+}
+
+func TestLockIsExclusive(t *testing.T) {
+	c := newTestContext(t)
+	defer os.RemoveAll(c.AbsPath)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		winners int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// the others must see a complete lock, never an empty one
+			if _, err := c.Lock("pull", false); err == nil {
+				mu.Lock()
+				winners++
+				mu.Unlock()
+			} else if _, ok := err.(*ErrLocked); !ok {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if winners != 1 {
+		t.Errorf("%d processes acquired the lock; want 1", winners)
+	}
+	if names, _ := ioutil.ReadDir(gdPath(c.AbsPath)); len(names) != 1 {
+		t.Errorf("%d files are left in the gd directory; want only the lock", len(names))
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const profileExt = ".json"
+
+// ErrInvalidProfileName is returned if a profile name is empty or could
+// name a file outside of the profiles directory.
+var ErrInvalidProfileName = errors.New("profile name must not be empty or contain /, \\ or ..")
+
+// ErrProfileNotExists is returned if there is no profile with the
+// requested name.
+type ErrProfileNotExists string
+
+func (e ErrProfileNotExists) Error() string {
+	return fmt.Sprintf("profile %s doesn't exist; use gd profile add %s", string(e), string(e))
+}
+
+// ProfilesPath returns the directory that holds the user's profiles.
+func ProfilesPath() string {
+	return path.Join(UserConfigPath(), "profiles")
+}
+
+// ProfileExists reports whether there is a profile with the name.
+func ProfileExists(name string) bool {
+	if ValidateProfileName(name) != nil {
+		return false
+	}
+	_, err := os.Stat(profilePath(name))
+	return err == nil
+}
+
+// ReadProfile reads the credentials of the named profile. Its secrets
+// are read by LoadSecrets once they are needed.
+func ReadProfile(name string) (c *Credentials, err error) {
+	if err = ValidateProfileName(name); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = ioutil.ReadFile(profilePath(name)); err != nil {
+		if os.IsNotExist(err) {
+			err = ErrProfileNotExists(name)
+		}
+		return
+	}
+	c = &Credentials{}
+	if err = json.Unmarshal(data, c); err != nil {
+		return
+	}
+	c.readEnv()
+	return
+}
+
+// WriteProfile saves the credentials as the named profile, replacing
+// it if it exists.
+func WriteProfile(name string, c *Credentials) (err error) {
+	if err = ValidateProfileName(name); err != nil {
+		return
+	}
+	var saved *Credentials
+	if saved, err = c.writeSecrets("profile:" + name); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(saved); err != nil {
+		return
+	}
+	if err = os.MkdirAll(ProfilesPath(), 0700); err != nil {
+		return
+	}
+	return ioutil.WriteFile(profilePath(name), data, 0600)
+}
+
+// RemoveProfile removes the named profile and its secrets.
+func RemoveProfile(name string) (err error) {
+	if err = ValidateProfileName(name); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = ioutil.ReadFile(profilePath(name)); err != nil {
+		if os.IsNotExist(err) {
+			err = ErrProfileNotExists(name)
+		}
+		return
+	}
+	c := &Credentials{}
+	if err = json.Unmarshal(data, c); err != nil {
+		return
+	}
+	var store SecretStore
+	if store, err = NewSecretStore(c); err != nil {
+		return
+	}
+	if store != nil && c.SecretRef != "" {
+		if err = store.Delete(c.SecretRef); err != nil && err != ErrSecretNotFound {
+			return
+		}
+	}
+	return os.Remove(profilePath(name))
+}
+
+// ListProfiles returns the names of the user's profiles in order.
+func ListProfiles() (names []string, err error) {
+	var infos []os.FileInfo
+	if infos, err = ioutil.ReadDir(ProfilesPath()); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), profileExt) {
+			names = append(names, strings.TrimSuffix(info.Name(), profileExt))
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// ValidateProfileName returns ErrInvalidProfileName if the name can't
+// be used as a profile name.
+func ValidateProfileName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return ErrInvalidProfileName
+	}
+	return nil
+}
+
+func profilePath(name string) string {
+	return path.Join(ProfilesPath(), name+profileExt)
+}
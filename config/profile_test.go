@@ -0,0 +1,39 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "testing"
+
+func TestValidateProfileName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"work", true},
+		{"me@example.com", true},
+		{"a.b", true},
+		{"", false},
+		{"..", false},
+		{"../credentials", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{"a..b", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateProfileName(tt.name); (err == nil) != tt.valid {
+			t.Errorf("ValidateProfileName(%q) = %v; want valid %t", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+
+	"code.google.com/p/go.crypto/scrypt"
+	"code.google.com/p/go.crypto/ssh/terminal"
+)
+
+const (
+	// SecretStoreFile keeps the secrets in a file in the user's config
+	// directory, encrypted with a passphrase.
+	SecretStoreFile = "file"
+
+	// SecretStoreKeyring keeps the secrets in the system keyring; the
+	// Secret Service on Linux and the Keychain on OS X.
+	SecretStoreKeyring = "keyring"
+
+	// SecretStoreHelper delegates to an external credential helper that
+	// speaks the same protocol as git's credential helpers.
+	SecretStoreHelper = "helper"
+)
+
+// EnvPassphrase is the environment variable that holds the passphrase
+// of the encrypted secrets file. If it is not set, the passphrase is
+// read from the terminal.
+const EnvPassphrase = "GD_PASSPHRASE"
+
+const keyringService = "gd"
+
+var (
+	ErrSecretNotFound = errors.New("secret not found in the secret store")
+	ErrBadPassphrase  = errors.New("cannot decrypt secrets; wrong passphrase?")
+)
+
+// Secrets are the sensitive parts of a context's credentials.
+type Secrets struct {
+	ClientSecret string `json:"client_secret,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// SecretStore keeps secrets under a reference that is saved in the
+// credentials file in their place.
+type SecretStore interface {
+	Get(ref string) (*Secrets, error)
+	Put(ref string, s *Secrets) error
+	Delete(ref string) error
+}
+
+// NewSecretStore returns the secret store the credentials are configured
+// to use, or nil if the secrets are kept in the credentials file.
+func NewSecretStore(c *Credentials) (SecretStore, error) {
+	switch c.SecretStore {
+	case "":
+		return nil, nil
+	case SecretStoreFile:
+		return &fileStore{path: path.Join(UserConfigPath(), "secrets")}, nil
+	case SecretStoreKeyring:
+		return &keyringStore{}, nil
+	case SecretStoreHelper:
+		if c.SecretHelper == "" {
+			return nil, errors.New("no credential helper is configured")
+		}
+		return &helperStore{command: c.SecretHelper}, nil
+	}
+	return nil, fmt.Errorf("unknown secret store: %s", c.SecretStore)
+}
+
+// UserConfigPath returns the user level gd configuration directory.
+func UserConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return path.Join(dir, "gd")
+	}
+	return path.Join(os.Getenv("HOME"), ".config", "gd")
+}
+
+// fileStore keeps all of the secrets of the user in a single file,
+// encrypted with AES-GCM and a key derived from a passphrase by scrypt.
+// The file is laid out as salt, nonce and the sealed JSON encoding of
+// the secrets keyed by their references.
+type fileStore struct {
+	path string
+}
+
+// fileStoreKey caches the passphrase and the last derived key, so that
+// the passphrase is asked for and stretched at most once per process.
+var fileStoreKey struct {
+	sync.Mutex
+	passphrase []byte
+	salt       []byte
+	key        []byte
+}
+
+const (
+	fileStoreSaltLen = 16
+	fileStoreKeyLen  = 32
+)
+
+func (s *fileStore) Get(ref string) (*Secrets, error) {
+	all, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	secrets, ok := all[ref]
+	if !ok {
+		return nil, ErrSecretNotFound
+	}
+	return secrets, nil
+}
+
+func (s *fileStore) Put(ref string, secrets *Secrets) error {
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	all[ref] = secrets
+	return s.write(all)
+}
+
+func (s *fileStore) Delete(ref string) error {
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	delete(all, ref)
+	return s.write(all)
+}
+
+func (s *fileStore) read() (all map[string]*Secrets, err error) {
+	all = make(map[string]*Secrets)
+	var data []byte
+	if data, err = ioutil.ReadFile(s.path); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	if len(data) < fileStoreSaltLen {
+		return nil, ErrBadPassphrase
+	}
+	salt, data := data[:fileStoreSaltLen], data[fileStoreSaltLen:]
+	var aead cipher.AEAD
+	if aead, err = s.cipher(salt); err != nil {
+		return
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, ErrBadPassphrase
+	}
+	nonce, data := data[:aead.NonceSize()], data[aead.NonceSize():]
+	var plain []byte
+	if plain, err = aead.Open(nil, nonce, data, nil); err != nil {
+		return nil, ErrBadPassphrase
+	}
+	err = json.Unmarshal(plain, &all)
+	return
+}
+
+func (s *fileStore) write(all map[string]*Secrets) (err error) {
+	var plain []byte
+	if plain, err = json.Marshal(all); err != nil {
+		return
+	}
+	fileStoreKey.Lock()
+	salt := fileStoreKey.salt
+	fileStoreKey.Unlock()
+	if salt == nil {
+		salt = make([]byte, fileStoreSaltLen)
+		if _, err = io.ReadFull(rand.Reader, salt); err != nil {
+			return
+		}
+	}
+	var aead cipher.AEAD
+	if aead, err = s.cipher(salt); err != nil {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return
+	}
+	data := append(append([]byte{}, salt...), nonce...)
+	data = aead.Seal(data, nonce, plain, nil)
+	if err = os.MkdirAll(path.Dir(s.path), 0700); err != nil {
+		return
+	}
+	return ioutil.WriteFile(s.path, data, 0600)
+}
+
+func (s *fileStore) cipher(salt []byte) (aead cipher.AEAD, err error) {
+	var key []byte
+	if key, err = fileStoreKeyFor(salt); err != nil {
+		return
+	}
+	var block cipher.Block
+	if block, err = aes.NewCipher(key); err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+// fileStoreKeyFor returns the key derived from the passphrase and salt.
+func fileStoreKeyFor(salt []byte) (key []byte, err error) {
+	fileStoreKey.Lock()
+	defer fileStoreKey.Unlock()
+	if fileStoreKey.key != nil && bytes.Equal(fileStoreKey.salt, salt) {
+		return fileStoreKey.key, nil
+	}
+	if fileStoreKey.passphrase == nil {
+		if fileStoreKey.passphrase, err = readPassphrase(); err != nil {
+			return
+		}
+	}
+	if key, err = scrypt.Key(fileStoreKey.passphrase, salt, 1<<15, 8, 1, fileStoreKeyLen); err != nil {
+		return
+	}
+	fileStoreKey.salt = append([]byte{}, salt...)
+	fileStoreKey.key = key
+	return
+}
+
+func readPassphrase() ([]byte, error) {
+	if p := os.Getenv(EnvPassphrase); p != "" {
+		return []byte(p), nil
+	}
+	fmt.Print("Passphrase for the gd secrets: ")
+	p, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	return p, err
+}
+
+// keyringStore keeps the JSON encoding of the secrets in the system
+// keyring by shelling out to secret-tool on Linux and security on OS X.
+// The secrets are always passed on the standard input, never as
+// arguments that other users could see.
+type keyringStore struct{}
+
+func (s *keyringStore) Get(ref string) (*Secrets, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", ref, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "ref", ref)
+	}
+	out, err := cmd.Output()
+	if err != nil || len(out) == 0 {
+		return nil, ErrSecretNotFound
+	}
+	secrets := &Secrets{}
+	if err = json.Unmarshal(bytes.TrimSpace(out), secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (s *keyringStore) Put(ref string, secrets *Secrets) error {
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		// security reads the command from stdin in interactive mode;
+		// -X takes the password hex encoded
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+			keyringService, securityQuote(ref), hex.EncodeToString(data)))
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label=gd "+ref, "service", keyringService, "ref", ref)
+		cmd.Stdin = bytes.NewReader(data)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot store secrets in the keyring: %v: %s", err, out)
+	}
+	return nil
+}
+
+func (s *keyringStore) Delete(ref string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", ref)
+	} else {
+		cmd = exec.Command("secret-tool", "clear", "service", keyringService, "ref", ref)
+	}
+	return cmd.Run()
+}
+
+// securityQuote quotes an argument of a command for security -i.
+func securityQuote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// helperStore talks to an external credential helper. Like git, the
+// helper is invoked with one of get, store or erase as its last
+// argument and exchanges key=value lines, terminated by a blank line,
+// on its standard input and output. The keys are ref, client_secret
+// and refresh_token. A helper name without spaces or slashes refers to
+// the gd-credential-<name> program.
+type helperStore struct {
+	command string
+}
+
+func (s *helperStore) Get(ref string) (*Secrets, error) {
+	out, err := s.run("get", map[string]string{"ref": ref})
+	if err != nil {
+		return nil, err
+	}
+	if out["client_secret"] == "" && out["refresh_token"] == "" {
+		return nil, ErrSecretNotFound
+	}
+	return &Secrets{ClientSecret: out["client_secret"], RefreshToken: out["refresh_token"]}, nil
+}
+
+func (s *helperStore) Put(ref string, secrets *Secrets) error {
+	_, err := s.run("store", map[string]string{
+		"ref":           ref,
+		"client_secret": secrets.ClientSecret,
+		"refresh_token": secrets.RefreshToken,
+	})
+	return err
+}
+
+func (s *helperStore) Delete(ref string) error {
+	_, err := s.run("erase", map[string]string{"ref": ref})
+	return err
+}
+
+func (s *helperStore) run(action string, in map[string]string) (out map[string]string, err error) {
+	var cmd *exec.Cmd
+	if strings.ContainsAny(s.command, " /") {
+		cmd = exec.Command("sh", "-c", s.command+" "+action)
+	} else {
+		cmd = exec.Command("gd-credential-"+s.command, action)
+	}
+	var stdin bytes.Buffer
+	for k, v := range in {
+		if v != "" {
+			fmt.Fprintf(&stdin, "%s=%s\n", k, v)
+		}
+	}
+	stdin.WriteString("\n")
+	cmd.Stdin = &stdin
+	cmd.Stderr = os.Stderr
+
+	var stdout []byte
+	if stdout, err = cmd.Output(); err != nil {
+		return nil, fmt.Errorf("credential helper %s failed: %v", s.command, err)
+	}
+	out = make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(stdout))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		if i := strings.Index(line, "="); i > 0 {
+			out[line[:i]] = line[i+1:]
+		}
+	}
+	return out, scanner.Err()
+}
@@ -0,0 +1,70 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestFileStoreCachesPassphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv(EnvPassphrase, os.Getenv(EnvPassphrase))
+
+	os.Setenv(EnvPassphrase, "secret")
+	want := &Secrets{ClientSecret: "cs", RefreshToken: "rt"}
+	if err = (&fileStore{path: path.Join(dir, "secrets")}).Put("ref", want); err != nil {
+		t.Fatal(err)
+	}
+	// a second store in the same process must not ask again
+	os.Setenv(EnvPassphrase, "")
+	got, err := (&fileStore{path: path.Join(dir, "secrets")}).Get("ref")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *want {
+		t.Errorf("Get(ref) = %+v; want %+v", got, want)
+	}
+}
+
+func TestSecurityQuote(t *testing.T) {
+	tests := []struct{ arg, want string }{
+		{"/home/me/gd", `"/home/me/gd"`},
+		{"profile:my work", `"profile:my work"`},
+		{`a"b\c`, `"a\"b\\c"`},
+	}
+	for _, tt := range tests {
+		if got := securityQuote(tt.arg); got != tt.want {
+			t.Errorf("securityQuote(%q) = %s; want %s", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestLoadSecretsBeforeWrite(t *testing.T) {
+	// freshly authorized credentials hold their secrets until written
+	c := &Credentials{SecretStore: SecretStoreFile, ClientSecret: "cs", RefreshToken: "rt"}
+	if err := c.LoadSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if c.ClientSecret != "cs" || c.RefreshToken != "rt" {
+		t.Errorf("LoadSecrets() = %q, %q; want cs, rt", c.ClientSecret, c.RefreshToken)
+	}
+}
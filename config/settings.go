@@ -0,0 +1,261 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Origins of the settings, in increasing precedence.
+const (
+	OriginDefault = "default"
+	OriginUser    = "user"
+	OriginContext = "context"
+	OriginEnv     = "env"
+	OriginFlag    = "flag"
+)
+
+const (
+	kindBool = iota
+	kindInt
+	kindList
+	kindString
+	kindDuration
+)
+
+type setting struct {
+	kind  int
+	value string
+}
+
+// settings are the known settings and their built-in defaults.
+var settings = map[string]setting{
+	"recursive":          {kindBool, "true"},
+	"hidden":             {kindBool, "false"},
+	"no-prompt":          {kindBool, "false"},
+	"concurrency":        {kindInt, "4"},
+	"ignore":             {kindList, ""},
+	"export":             {kindList, "docx,xlsx,pptx,svg"},
+	"max-deletes":        {kindInt, "100"},
+	"max-delete-percent": {kindInt, "0"},
+	"trash-retention":    {kindDuration, "720h"},
+}
+
+// Settings are the behaviour options of a context. They are layered
+// from the built-in defaults, the user's config file, the context's
+// .gd/config file, GD_* environment variables and command line flags,
+// each overriding the previous ones.
+//
+// Config files have a key = value pair per line; lines that start with
+// # are comments. Lists are comma separated. The environment variable
+// of a key is its upper case form prefixed with GD_ and with dashes
+// replaced by underscores, e.g. GD_MAX_DELETES.
+type Settings struct {
+	values  map[string]string
+	origins map[string]string
+}
+
+// LoadSettings loads the settings of the context. If the context is
+// nil, only the defaults, the user's settings and the environment are
+// taken into account.
+func LoadSettings(c *Context) (s *Settings, err error) {
+	s = &Settings{values: make(map[string]string), origins: make(map[string]string)}
+	for key, def := range settings {
+		s.values[key], s.origins[key] = def.value, OriginDefault
+	}
+	files := []struct{ path, origin string }{{UserSettingsPath(), OriginUser}}
+	if c != nil {
+		files = append(files, struct{ path, origin string }{c.SettingsPath(), OriginContext})
+	}
+	for _, f := range files {
+		var values map[string]string
+		if values, err = ReadSettingsFile(f.path); err != nil {
+			return
+		}
+		for key, value := range values {
+			if err = s.Set(key, value, f.origin); err != nil {
+				return nil, fmt.Errorf("%s: %v", f.path, err)
+			}
+		}
+	}
+	for key := range settings {
+		if value := os.Getenv(EnvOf(key)); value != "" {
+			if err = s.Set(key, value, OriginEnv); err != nil {
+				return nil, fmt.Errorf("%s: %v", EnvOf(key), err)
+			}
+		}
+	}
+	return
+}
+
+// EnvOf returns the environment variable that overrides the key.
+func EnvOf(key string) string {
+	return "GD_" + strings.ToUpper(strings.Replace(key, "-", "_", -1))
+}
+
+// IsSetting reports whether key is a known setting.
+func IsSetting(key string) bool {
+	_, ok := settings[key]
+	return ok
+}
+
+// Keys returns the known settings in order.
+func (s *Settings) Keys() (keys []string) {
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// Set validates and sets the value of a key from the given origin.
+func (s *Settings) Set(key, value, origin string) error {
+	if err := validateSetting(key, value); err != nil {
+		return err
+	}
+	s.values[key], s.origins[key] = strings.TrimSpace(value), origin
+	return nil
+}
+
+func (s *Settings) Get(key string) string {
+	return s.values[key]
+}
+
+// Origin returns where the current value of the key comes from.
+func (s *Settings) Origin(key string) string {
+	return s.origins[key]
+}
+
+func (s *Settings) Bool(key string) bool {
+	b, _ := strconv.ParseBool(s.values[key])
+	return b
+}
+
+func (s *Settings) Int(key string) int {
+	i, _ := strconv.Atoi(s.values[key])
+	return i
+}
+
+func (s *Settings) Duration(key string) time.Duration {
+	d, _ := time.ParseDuration(s.values[key])
+	return d
+}
+
+func (s *Settings) List(key string) (list []string) {
+	for _, item := range strings.Split(s.values[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return
+}
+
+func validateSetting(key, value string) (err error) {
+	def, ok := settings[key]
+	if !ok {
+		return fmt.Errorf("unknown setting: %s", key)
+	}
+	value = strings.TrimSpace(value)
+	switch def.kind {
+	case kindBool:
+		_, err = strconv.ParseBool(value)
+	case kindInt:
+		_, err = strconv.Atoi(value)
+	case kindDuration:
+		var d time.Duration
+		if d, err = time.ParseDuration(value); err == nil && d <= 0 {
+			err = errors.New("not positive")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %s", key, value)
+	}
+	return nil
+}
+
+// UserSettingsPath returns the path of the user's config file, whose
+// settings apply to all contexts.
+func UserSettingsPath() string {
+	return path.Join(UserConfigPath(), "config")
+}
+
+// ReadSettingsFile reads the key = value pairs of a config file. A
+// missing file has no settings.
+func ReadSettingsFile(p string) (values map[string]string, err error) {
+	values = make(map[string]string)
+	var data []byte
+	if data, err = ioutil.ReadFile(p); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: expected key = value", p, n)
+		}
+		values[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+	}
+	err = scanner.Err()
+	return
+}
+
+// WriteSetting sets, or removes if value is empty, a key in a config
+// file.
+func WriteSetting(p, key, value string) (err error) {
+	if value != "" {
+		if err = validateSetting(key, value); err != nil {
+			return
+		}
+	}
+	var values map[string]string
+	if values, err = ReadSettingsFile(p); err != nil {
+		return
+	}
+	if value == "" {
+		delete(values, key)
+	} else {
+		values[key] = value
+	}
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s = %s\n", k, values[k])
+	}
+	if err = os.MkdirAll(path.Dir(p), 0755); err != nil {
+		return
+	}
+	return ioutil.WriteFile(p, buf.Bytes(), 0644)
+}
@@ -0,0 +1,79 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestLoadSettingsPrecedence(t *testing.T) {
+	c := newTestContext(t)
+	defer os.RemoveAll(c.AbsPath)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	defer os.Setenv("GD_MAX_DELETES", os.Getenv("GD_MAX_DELETES"))
+
+	os.Setenv("XDG_CONFIG_HOME", c.AbsPath)
+	if err := os.MkdirAll(path.Dir(UserSettingsPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	user := "# user\nconcurrency = 8\nhidden = true\nmax-deletes = 10\n"
+	if err := ioutil.WriteFile(UserSettingsPath(), []byte(user), 0644); err != nil {
+		t.Fatal(err)
+	}
+	context := "hidden = false\nmax-deletes = 20\n"
+	if err := ioutil.WriteFile(c.SettingsPath(), []byte(context), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GD_MAX_DELETES", "30")
+
+	s, err := LoadSettings(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Set("max-delete-percent", "5", OriginFlag); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key, value, origin string
+	}{
+		{"recursive", "true", OriginDefault},
+		{"concurrency", "8", OriginUser},
+		{"hidden", "false", OriginContext},
+		{"max-deletes", "30", OriginEnv},
+		{"max-delete-percent", "5", OriginFlag},
+	}
+	for _, tt := range tests {
+		if v, o := s.Get(tt.key), s.Origin(tt.key); v != tt.value || o != tt.origin {
+			t.Errorf("%s = %s from %s; want %s from %s", tt.key, v, o, tt.value, tt.origin)
+		}
+	}
+
+	if err = s.Set("concurrency", "many", OriginFlag); err == nil {
+		t.Errorf("Set(concurrency, many) succeeded")
+	}
+	if err = s.Set("trash-retention", "0s", OriginFlag); err == nil {
+		t.Errorf("Set(trash-retention, 0s) succeeded")
+	}
+	if d := s.Duration("trash-retention"); d != 720*time.Hour {
+		t.Errorf("trash-retention = %v; want 720h", d)
+	}
+	if err = s.Set("colour", "red", OriginFlag); err == nil {
+		t.Errorf("Set(colour, red) succeeded")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// SparsePath returns the path of the file that lists the subtrees of a
+// sparse checkout, one pattern per line.
+func (c *Context) SparsePath() string {
+	return path.Join(gdPath(c.AbsPath), "sparse")
+}
+
+// ReadSparse returns the patterns of the subtrees that are checked out.
+// If there are none, the whole context is checked out.
+func (c *Context) ReadSparse() (patterns []string, err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(c.SparsePath()); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	err = scanner.Err()
+	return
+}
+
+// WriteSparse replaces the patterns of the sparse checkout. Writing no
+// patterns disables the sparse checkout.
+func (c *Context) WriteSparse(patterns []string) error {
+	if len(patterns) == 0 {
+		err := os.Remove(c.SparsePath())
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(gdPath(c.AbsPath), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.SparsePath(), []byte(strings.Join(patterns, "\n")+"\n"), 0644)
+}
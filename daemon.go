@@ -0,0 +1,279 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"os/signal"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rakyll/drive/config"
+)
+
+const (
+	// DefaultDaemonInterval is how often the daemon syncs each context.
+	DefaultDaemonInterval = 5 * time.Minute
+
+	// Number of recent errors the daemon keeps for each context.
+	maxDaemonErrors = 20
+)
+
+var (
+	ErrDaemonRunning  = errors.New("a gd daemon is already listening on the socket")
+	ErrUnknownContext = errors.New("the daemon doesn't sync the context")
+)
+
+// DefaultDaemonSocket returns the path of the daemon's control socket.
+func DefaultDaemonSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return path.Join(dir, "gd.sock")
+	}
+	return path.Join(config.UserConfigPath(), "daemon.sock")
+}
+
+// DaemonArgs are the arguments of the daemon's control calls. An empty
+// context applies the call to all of the contexts.
+type DaemonArgs struct {
+	Context string
+}
+
+// DaemonError is an error of a sync cycle.
+type DaemonError struct {
+	Time time.Time
+	Err  string
+}
+
+// ContextStatus is the state of a context synced by the daemon.
+type ContextStatus struct {
+	Path     string
+	Paused   bool
+	Syncing  bool
+	LastSync time.Time
+	Errors   []DaemonError
+}
+
+// DaemonStatus is the reply of the daemon's control calls.
+type DaemonStatus struct {
+	Contexts []*ContextStatus
+}
+
+// daemonContext syncs a single context. Its Commands, and so its remote
+// client and OAuth 2.0 token, are kept between the cycles.
+type daemonContext struct {
+	g     *Commands
+	force chan bool
+
+	mu     sync.Mutex
+	status ContextStatus
+}
+
+func (d *daemonContext) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.sync()
+		select {
+		case <-ticker.C:
+		case <-d.force:
+		}
+	}
+}
+
+// sync syncs the whole context both ways, unless it is paused.
+func (d *daemonContext) sync() {
+	d.mu.Lock()
+	if d.status.Paused {
+		d.mu.Unlock()
+		return
+	}
+	d.status.Syncing = true
+	d.mu.Unlock()
+
+	errs := d.cycle()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status.Syncing = false
+	d.status.LastSync = time.Now()
+	for _, err := range errs {
+		d.status.Errors = append(d.status.Errors, DaemonError{Time: time.Now(), Err: err.Error()})
+	}
+	if n := len(d.status.Errors); n > maxDaemonErrors {
+		d.status.Errors = d.status.Errors[n-maxDaemonErrors:]
+	}
+}
+
+func (d *daemonContext) cycle() (errs []error) {
+	l, err := d.g.context.Lock("daemon", true)
+	if err != nil {
+		return []error{err}
+	}
+	defer l.Unlock()
+	d.g.opts.Path = "/"
+	if err = d.g.Sync(); err != nil {
+		return []error{err}
+	}
+	return
+}
+
+func (d *daemonContext) snapshot() *ContextStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.status
+	s.Errors = append([]DaemonError(nil), d.status.Errors...)
+	return &s
+}
+
+// Daemon is the control service of a running daemon, served over its
+// Unix socket with net/rpc.
+type Daemon struct {
+	contexts []*daemonContext
+}
+
+func (s *Daemon) Status(args *DaemonArgs, reply *DaemonStatus) error {
+	return s.each(args, reply, func(d *daemonContext) {})
+}
+
+func (s *Daemon) Pause(args *DaemonArgs, reply *DaemonStatus) error {
+	return s.each(args, reply, func(d *daemonContext) {
+		d.mu.Lock()
+		d.status.Paused = true
+		d.mu.Unlock()
+	})
+}
+
+func (s *Daemon) Resume(args *DaemonArgs, reply *DaemonStatus) error {
+	return s.each(args, reply, func(d *daemonContext) {
+		d.mu.Lock()
+		d.status.Paused = false
+		d.mu.Unlock()
+	})
+}
+
+// Sync starts a sync cycle right away, or as soon as the current one
+// finishes.
+func (s *Daemon) Sync(args *DaemonArgs, reply *DaemonStatus) error {
+	return s.each(args, reply, func(d *daemonContext) {
+		select {
+		case d.force <- true:
+		default:
+		}
+	})
+}
+
+func (s *Daemon) each(args *DaemonArgs, reply *DaemonStatus, fn func(d *daemonContext)) error {
+	found := false
+	for _, d := range s.contexts {
+		if args.Context != "" && args.Context != d.g.context.AbsPath {
+			continue
+		}
+		found = true
+		fn(d)
+		reply.Contexts = append(reply.Contexts, d.snapshot())
+	}
+	if !found {
+		return ErrUnknownContext
+	}
+	return nil
+}
+
+// RunDaemon syncs the contexts every interval, until it is interrupted,
+// and serves the control calls on the Unix socket. opts[i] are the
+// options of contexts[i]; prompts are always disabled.
+func RunDaemon(contexts []*config.Context, opts []*Options, socket string, interval time.Duration) (err error) {
+	if conn, e := net.Dial("unix", socket); e == nil {
+		conn.Close()
+		return ErrDaemonRunning
+	}
+	os.Remove(socket)
+	if err = os.MkdirAll(path.Dir(socket), 0700); err != nil {
+		return
+	}
+	var l net.Listener
+	if l, err = net.Listen("unix", socket); err != nil {
+		return
+	}
+	defer os.Remove(socket)
+
+	daemon := &Daemon{}
+	for i, context := range contexts {
+		o := *opts[i]
+		o.IsNoPrompt = true
+		o.IsInteractive = false
+		d := &daemonContext{
+			g:      New(context, &o),
+			force:  make(chan bool, 1),
+			status: ContextStatus{Path: context.AbsPath},
+		}
+		daemon.contexts = append(daemon.contexts, d)
+		go d.run(interval)
+	}
+
+	server := rpc.NewServer()
+	if err = server.Register(daemon); err != nil {
+		return
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+	fmt.Println("Listening on", socket)
+	server.Accept(l)
+	return nil
+}
+
+// DaemonControl makes a control call, one of Status, Pause, Resume or
+// Sync, to the daemon listening on the socket and prints the reply. If
+// showErrors is set, the recent errors of each context are printed too.
+func DaemonControl(socket, method, context string, showErrors bool) (err error) {
+	var client *rpc.Client
+	if client, err = rpc.Dial("unix", socket); err != nil {
+		return fmt.Errorf("cannot connect to the gd daemon: %v", err)
+	}
+	defer client.Close()
+	reply := &DaemonStatus{}
+	if err = client.Call("Daemon."+method, &DaemonArgs{Context: context}, reply); err != nil {
+		return
+	}
+	for _, c := range reply.Contexts {
+		state := "idle"
+		switch {
+		case c.Syncing:
+			state = "syncing"
+		case c.Paused:
+			state = "paused"
+		}
+		last := "never"
+		if !c.LastSync.IsZero() {
+			last = c.LastSync.Format(time.RFC3339)
+		}
+		fmt.Printf("%s %s, last synced %s, %d recent error(s)\n", c.Path, state, last, len(c.Errors))
+		if showErrors {
+			for _, e := range c.Errors {
+				fmt.Printf("  %s %s\n", e.Time.Format(time.RFC3339), e.Err)
+			}
+		}
+	}
+	return
+}
@@ -14,7 +14,54 @@
 
 package drive
 
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
 func (g *Commands) Diff() error {
 	panic("not implemented")
 	return nil
 }
+
+// diffChange prints a unified diff between the local and the remote
+// copies of a modified file.
+func (g *Commands) diffChange(c *Change) (err error) {
+	if c.Op() != OpMod || c.Src.IsDir || c.Dest.IsDir {
+		return errors.New("diff is only available for modified files")
+	}
+	remote, local := c.Src, c.Dest
+	if remote.Id == "" {
+		remote, local = local, remote
+	}
+
+	var tmp *os.File
+	if tmp, err = ioutil.TempFile("", "gd-diff"); err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var blob io.ReadCloser
+	if blob, err = g.rem.Download(remote.Id); err != nil {
+		return
+	}
+	defer blob.Close()
+	if _, err = io.Copy(tmp, blob); err != nil {
+		return
+	}
+
+	cmd := exec.Command("diff", "-u", local.BlobAt, tmp.Name())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// diff exits with 1 if the files differ, which is expected here.
+	if err = cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil
+		}
+	}
+	return
+}
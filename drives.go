@@ -0,0 +1,36 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+)
+
+// Drives prints the ids and names of the shared drives available to
+// the user.
+func (g *Commands) Drives() (err error) {
+	var drives []*Drive
+	if drives, err = g.rem.FindDrives(); err != nil {
+		return
+	}
+	if len(drives) == 0 {
+		fmt.Println("No shared drives.")
+		return
+	}
+	for _, d := range drives {
+		fmt.Println(d.Id, d.Name)
+	}
+	return
+}
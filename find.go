@@ -0,0 +1,112 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrUnknownType = errors.New("unknown type; use file, folder, document, spreadsheet, presentation, drawing or form")
+
+// Query describes the remote files to find. Its zero value matches
+// every file that isn't trashed.
+type Query struct {
+	// Name matches the files whose name contains it
+	Name string
+	// Type is file, folder or the type of a native Google document
+	// such as document, spreadsheet or presentation
+	Type  string
+	Owner string
+	Mime  string
+	// ModifiedAfter matches the files modified after it, if not zero
+	ModifiedAfter time.Time
+	Starred       bool
+	// Shared matches the files shared with others
+	Shared bool
+	// Raw is a Drive query expression that is and'ed with the rest
+	Raw string
+}
+
+// Compile returns the Drive query expression of the query.
+func (q *Query) Compile() (string, error) {
+	exprs := []string{"trashed=false"}
+	if q.Name != "" {
+		exprs = append(exprs, fmt.Sprintf("title contains '%s'", escapeQuery(q.Name)))
+	}
+	switch q.Type {
+	case "":
+	case "file":
+		exprs = append(exprs, fmt.Sprintf("mimeType != '%s'", folderMimeType))
+	case "folder":
+		exprs = append(exprs, fmt.Sprintf("mimeType = '%s'", folderMimeType))
+	case "document", "spreadsheet", "presentation", "drawing", "form":
+		exprs = append(exprs, fmt.Sprintf("mimeType = 'application/vnd.google-apps.%s'", q.Type))
+	default:
+		return "", ErrUnknownType
+	}
+	if q.Owner != "" {
+		exprs = append(exprs, fmt.Sprintf("'%s' in owners", escapeQuery(q.Owner)))
+	}
+	if q.Mime != "" {
+		exprs = append(exprs, fmt.Sprintf("mimeType = '%s'", escapeQuery(q.Mime)))
+	}
+	if !q.ModifiedAfter.IsZero() {
+		exprs = append(exprs, fmt.Sprintf("modifiedDate > '%s'", q.ModifiedAfter.UTC().Format(time.RFC3339)))
+	}
+	if q.Starred {
+		exprs = append(exprs, "starred=true")
+	}
+	if q.Raw != "" {
+		exprs = append(exprs, "("+q.Raw+")")
+	}
+	return strings.Join(exprs, " and "), nil
+}
+
+type byFoundPath []*FoundFile
+
+func (s byFoundPath) Len() int           { return len(s) }
+func (s byFoundPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byFoundPath) Less(i, j int) bool { return s[i].Path < s[j].Path }
+
+// Find prints the context relative paths of the remote files under the
+// path that match the query, one per line, so that they can be passed
+// to pull. Like the rest of gd, it prints them with a leading slash.
+func (g *Commands) Find(q *Query) (err error) {
+	var expr string
+	if expr, err = q.Compile(); err != nil {
+		return
+	}
+	var files []*FoundFile
+	if files, err = g.rem.FindByQuery(expr); err != nil {
+		return
+	}
+	sort.Sort(byFoundPath(files))
+	prefix := strings.TrimSuffix(g.opts.Path, "/") + "/"
+	for _, f := range files {
+		if !strings.HasPrefix(f.Path, prefix) || g.isIgnored(f.Path) {
+			continue
+		}
+		// Drive can't search for the files shared by the user
+		if q.Shared && !f.Shared {
+			continue
+		}
+		fmt.Println(f.Path)
+	}
+	return
+}
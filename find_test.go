@@ -0,0 +1,45 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryCompile(t *testing.T) {
+	tests := []struct {
+		q    Query
+		want string
+		err  error
+	}{
+		{Query{}, "trashed=false", nil},
+		{Query{Name: "report"}, "trashed=false and title contains 'report'", nil},
+		{Query{Name: `it's a\b`}, `trashed=false and title contains 'it\'s a\\b'`, nil},
+		{Query{Type: "folder"}, "trashed=false and mimeType = 'application/vnd.google-apps.folder'", nil},
+		{Query{Type: "file"}, "trashed=false and mimeType != 'application/vnd.google-apps.folder'", nil},
+		{Query{Type: "spreadsheet"}, "trashed=false and mimeType = 'application/vnd.google-apps.spreadsheet'", nil},
+		{Query{Type: "video"}, "", ErrUnknownType},
+		{Query{Owner: "me@example.com", Starred: true}, "trashed=false and 'me@example.com' in owners and starred=true", nil},
+		{Query{ModifiedAfter: time.Date(2014, 1, 2, 3, 4, 5, 0, time.UTC)}, "trashed=false and modifiedDate > '2014-01-02T03:04:05Z'", nil},
+		{Query{Mime: "image/png", Raw: "a or b"}, "trashed=false and mimeType = 'image/png' and (a or b)", nil},
+	}
+	for _, tt := range tests {
+		got, err := tt.q.Compile()
+		if got != tt.want || err != tt.err {
+			t.Errorf("%+v.Compile() = %q, %v; want %q, %v", tt.q, got, err, tt.want, tt.err)
+		}
+	}
+}
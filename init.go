@@ -16,22 +16,74 @@ package drive
 
 import (
 	"os"
+	"path/filepath"
+
+	"github.com/rakyll/drive/config"
 )
 
+// Init authorizes the context. If a profile is given, the context is
+// bound to it, and the profile is created first if it doesn't exist.
+// If a shared drive or a remote root is given, the context is mapped
+// to that drive or folder instead of My Drive.
 func (g *Commands) Init() (err error) {
+	if g.opts.Profile != "" {
+		if !config.ProfileExists(g.opts.Profile) {
+			if err = g.ProfileAdd(); err != nil {
+				return
+			}
+		}
+		var creds *config.Credentials
+		if creds, err = config.ReadProfile(g.opts.Profile); err != nil {
+			return
+		}
+		g.context.Profile = g.opts.Profile
+		g.context.Credentials = *creds
+	} else if err = g.authorize(&g.context.Credentials); err != nil {
+		return
+	}
+	if g.opts.Drive != "" {
+		if g.context.DriveId, err = NewRemoteContext(g.context).ResolveDrive(g.opts.Drive); err != nil {
+			return
+		}
+	}
+	if g.opts.Root != "" {
+		if g.context.RootId, err = NewRemoteContext(g.context).ResolveRoot(g.opts.Root); err != nil {
+			return
+		}
+	}
+	return g.context.Write()
+}
+
+// authorize fills in the credentials from the options, either with a
+// service account key or by asking the user for authorization.
+func (g *Commands) authorize(creds *config.Credentials) (err error) {
 	var refresh string
 
-	g.context.ClientId = os.Getenv("GOOGLE_API_CLIENT_ID")
-	g.context.ClientSecret = os.Getenv("GOOGLE_API_CLIENT_SECRET")
-	if g.context.ClientId == "" || g.context.ClientSecret == "" {
-		g.context.ClientId = "354790962074-7rrlnuanmamgg1i4feed12dpuq871bvd.apps.googleusercontent.com"
-		g.context.ClientSecret = "RHjKdah8RrHFwu6fcc0uEVCw"
+	creds.SecretStore = g.opts.SecretStore
+	creds.SecretHelper = g.opts.SecretHelper
+	if creds.SecretHelper != "" {
+		creds.SecretStore = config.SecretStoreHelper
+	}
+
+	if g.opts.ServiceAccountKey != "" {
+		creds.Type = config.CredentialsServiceAccount
+		if creds.ServiceAccountKey, err = filepath.Abs(g.opts.ServiceAccountKey); err != nil {
+			return
+		}
+		creds.Subject = g.opts.Subject
+		return
+	}
+
+	creds.ClientId = os.Getenv("GOOGLE_API_CLIENT_ID")
+	creds.ClientSecret = os.Getenv("GOOGLE_API_CLIENT_SECRET")
+	if creds.ClientId == "" || creds.ClientSecret == "" {
+		creds.ClientId = "354790962074-7rrlnuanmamgg1i4feed12dpuq871bvd.apps.googleusercontent.com"
+		creds.ClientSecret = "RHjKdah8RrHFwu6fcc0uEVCw"
 	}
 
-	if refresh, err = RetrieveRefreshToken(g.context); err != nil {
+	if refresh, err = RetrieveRefreshToken(creds, g.opts.IsHeadless); err != nil {
 		return
 	}
-	g.context.RefreshToken = refresh
-	err = g.context.Write()
+	creds.RefreshToken = refresh
 	return
 }
@@ -0,0 +1,358 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+const (
+	cmdPush   = "push"
+	cmdPull   = "pull"
+	cmdUndo   = "undo"
+	cmdMkdir  = "mkdir"
+	cmdRm     = "rm"
+	cmdRevert = "revert"
+	cmdPut    = "put"
+	cmdMv     = "mv"
+	cmdCp     = "cp"
+
+	// opMove is the op of the journal entries of mv, which aren't
+	// changes of a push or a pull
+	opMove = "move"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+)
+
+var opNames = map[int]string{
+	OpAdd:    "add",
+	OpDelete: "delete",
+	OpMod:    "mod",
+}
+
+// JournalEntry is a single applied change, or an undo marker, in the
+// append-only journal of a context.
+type JournalEntry struct {
+	// Run identifies the push or pull that applied the change. It is
+	// also the name of the run's local trash entry.
+	Run     string `json:"run"`
+	Command string `json:"command"`
+	Op      string `json:"op,omitempty"`
+	Path    string `json:"path,omitempty"`
+	// From is the path a moved file was moved from.
+	From string `json:"from,omitempty"`
+	Id   string `json:"id,omitempty"`
+	// Revision and Md5Checksum describe the remote file before it was
+	// modified or deleted by a push.
+	Revision    string    `json:"revision,omitempty"`
+	Md5Checksum string    `json:"md5,omitempty"`
+	Time        time.Time `json:"time"`
+	// Undoes is set for undo markers to the run that has been undone.
+	Undoes string `json:"undoes,omitempty"`
+}
+
+func (e *JournalEntry) Symbol() string {
+	if e.Op == opMove {
+		return "\x1b[36mR\x1b[0m"
+	}
+	for op, name := range opNames {
+		if name == e.Op {
+			return opSymbol(op)
+		}
+	}
+	return ""
+}
+
+// Describe returns the path of the entry, and where it was moved from
+// if it is a move.
+func (e *JournalEntry) Describe() string {
+	if e.Op == opMove {
+		return e.From + " -> " + e.Path
+	}
+	return e.Path
+}
+
+// journalRun is a push, pull, mkdir or rm and the changes it has
+// applied.
+type journalRun struct {
+	Id      string
+	Command string
+	Time    time.Time
+	Entries []*JournalEntry
+	Undone  bool
+}
+
+// Log prints the runs recorded in the journal, most recent first.
+func (g *Commands) Log() (err error) {
+	var runs []*journalRun
+	if runs, err = g.readJournal(); err != nil {
+		return
+	}
+	if len(runs) == 0 {
+		fmt.Println("No runs are recorded.")
+		return
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		status := ""
+		if run.Undone {
+			status = " (undone)"
+		}
+		fmt.Printf("%s %s %s%s\n", run.Id, run.Command, run.Time.Format(time.RFC3339), status)
+		for _, e := range run.Entries {
+			fmt.Println("  ", e.Symbol(), e.Describe())
+		}
+	}
+	return
+}
+
+// Undo reverts the most recent run that hasn't been undone yet. Remote
+// files are untrashed, trashed or reverted to their previous revisions;
+// local files are restored from the run's local trash entry. The local
+// copies of the files that mkdir, rm and cp have made or removed are
+// undone along with the remote ones. An undo that fails partway can be
+// run again; the entries that were already reverted are left as they are.
+func (g *Commands) Undo() (err error) {
+	var runs []*journalRun
+	if runs, err = g.readJournal(); err != nil {
+		return
+	}
+	var run *journalRun
+	for i := len(runs) - 1; i >= 0 && run == nil; i-- {
+		if !runs[i].Undone {
+			run = runs[i]
+		}
+	}
+	if run == nil {
+		return ErrNothingToUndo
+	}
+
+	for _, e := range run.Entries {
+		fmt.Println(e.Symbol(), e.Describe())
+	}
+	if !g.confirm(fmt.Sprintf("Undo the %s of %s?", run.Command, run.Time.Format(time.RFC3339))) {
+		return
+	}
+	for i := len(run.Entries) - 1; i >= 0; i-- {
+		e := run.Entries[i]
+		switch run.Command {
+		case cmdPull:
+			err = g.undoLocal(e)
+		case cmdMv:
+			err = g.undoMove(e)
+		case cmdCp, cmdRm, cmdMkdir:
+			// the local copy goes along with the remote one
+			if err = g.undoRemote(e); err == nil {
+				err = g.undoLocal(e)
+			}
+		default:
+			err = g.undoRemote(e)
+		}
+		if err != nil {
+			return fmt.Errorf("undoing %s: %v", e.Path, err)
+		}
+	}
+	return g.appendJournal(&JournalEntry{
+		Run:     g.runId,
+		Command: cmdUndo,
+		Time:    time.Now(),
+		Undoes:  run.Id,
+	})
+}
+
+func (g *Commands) undoRemote(e *JournalEntry) error {
+	switch e.Op {
+	case opNames[OpAdd]:
+		return g.rem.Trash(e.Id)
+	case opNames[OpDelete]:
+		return g.rem.Untrash(e.Id)
+	case opNames[OpMod]:
+		if e.Revision == "" {
+			return fmt.Errorf("no previous revision is recorded")
+		}
+		return g.rem.Revert(e.Id, e.Revision)
+	}
+	return nil
+}
+
+// undoMove moves the file back, remotely and locally. A remote file that
+// is already back, by an earlier undo that failed partway, stays there.
+func (g *Commands) undoMove(e *JournalEntry) (err error) {
+	if f, findErr := g.rem.FindByPath(e.From); findErr != nil || f.Id != e.Id {
+		if _, statErr := os.Lstat(g.context.AbsPathOf(e.From)); statErr == nil {
+			return fmt.Errorf("%s exists locally", e.From)
+		}
+		var parentId, oldParentId string
+		if parentId, err = g.parentIdOf(e.Path); err != nil {
+			return
+		}
+		if oldParentId, err = g.parentIdOf(e.From); err != nil {
+			return
+		}
+		if err = g.rem.Move(e.Id, parentId, oldParentId, path.Base(e.From)); err != nil {
+			return
+		}
+	}
+	if err = g.moveLocal(e.Path, e.From); err != nil {
+		return
+	}
+	return g.moveSparse(e.Path, e.From)
+}
+
+// undoLocal restores the local copy from the run's local trash entry.
+// It can be repeated: a copy that is no longer in the trash entry has
+// already been restored by an earlier undo that failed partway.
+func (g *Commands) undoLocal(e *JournalEntry) error {
+	trashed := filepath.Join(g.context.TrashPath(), e.Run, e.Path)
+	switch e.Op {
+	case opNames[OpAdd]:
+		return g.moveToLocalTrash(e.Path)
+	case opNames[OpDelete]:
+		return moveAll(trashed, g.context.AbsPathOf(e.Path))
+	case opNames[OpMod]:
+		if _, err := os.Lstat(trashed); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err := g.moveToLocalTrash(e.Path); err != nil {
+			return err
+		}
+		return moveAll(trashed, g.context.AbsPathOf(e.Path))
+	}
+	return nil
+}
+
+// record appends an applied change to the journal. prev is the
+// destination file before the change, if there was one.
+func (g *Commands) record(command string, op int, p, id string, prev *File) {
+	e := &JournalEntry{
+		Run:     g.runId,
+		Command: command,
+		Op:      opNames[op],
+		Path:    p,
+		Id:      id,
+		Time:    time.Now(),
+	}
+	if prev != nil {
+		e.Revision = prev.HeadRevisionId
+		e.Md5Checksum = prev.Md5Checksum
+	}
+	if err := g.appendJournal(e); err != nil {
+		fmt.Println("cannot write to the journal:", err)
+	}
+}
+
+// recordMove appends a move of a remote file from src to dest to the
+// journal.
+func (g *Commands) recordMove(src, dest, id string) {
+	e := &JournalEntry{
+		Run:     g.runId,
+		Command: cmdMv,
+		Op:      opMove,
+		Path:    dest,
+		From:    src,
+		Id:      id,
+		Time:    time.Now(),
+	}
+	if err := g.appendJournal(e); err != nil {
+		fmt.Println("cannot write to the journal:", err)
+	}
+}
+
+func (g *Commands) appendJournal(e *JournalEntry) (err error) {
+	g.journalMu.Lock()
+	defer g.journalMu.Unlock()
+	var data []byte
+	if data, err = json.Marshal(e); err != nil {
+		return
+	}
+	// the gd directory of a context from the environment is only made
+	// once there is something to keep in it
+	if err = os.MkdirAll(filepath.Dir(g.context.JournalPath()), 0755); err != nil {
+		return
+	}
+	var f *os.File
+	if f, err = os.OpenFile(g.context.JournalPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600); err != nil {
+		return
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+// runPaths returns the paths changed by the run.
+func (g *Commands) runPaths(runId string) map[string]bool {
+	paths := make(map[string]bool)
+	runs, _ := g.readJournal()
+	for _, run := range runs {
+		if run.Id != runId {
+			continue
+		}
+		for _, e := range run.Entries {
+			paths[e.Path] = true
+		}
+	}
+	return paths
+}
+
+// readJournal reads the journal and groups its entries by run, in the
+// order the runs were recorded.
+func (g *Commands) readJournal() (runs []*journalRun, err error) {
+	var f *os.File
+	if f, err = os.Open(g.context.JournalPath()); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	defer f.Close()
+
+	byId := make(map[string]*journalRun)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := &JournalEntry{}
+		if err = json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return
+		}
+		if e.Command == cmdUndo {
+			if run, ok := byId[e.Undoes]; ok {
+				run.Undone = true
+			}
+			continue
+		}
+		run, ok := byId[e.Run]
+		if !ok {
+			run = &journalRun{Id: e.Run, Command: e.Command, Time: e.Time}
+			byId[e.Run] = run
+			runs = append(runs, run)
+		}
+		run.Entries = append(run.Entries, e)
+	}
+	err = scanner.Err()
+	return
+}
@@ -0,0 +1,156 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rakyll/drive/config"
+)
+
+func newTestCommands(t *testing.T) *Commands {
+	dir, err := ioutil.TempDir("", "gd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(dir, ".gd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Commands{
+		context: &config.Context{AbsPath: dir},
+		opts:    &Options{Path: "/", IsNoPrompt: true},
+		runId:   newRunId(),
+	}
+}
+
+func writeLocal(t *testing.T, g *Commands, p, content string) {
+	abs := g.context.AbsPathOf(p)
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(abs, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLocal(g *Commands, p string) string {
+	data, err := ioutil.ReadFile(g.context.AbsPathOf(p))
+	if err != nil {
+		return "<missing>"
+	}
+	return string(data)
+}
+
+func TestUndoPull(t *testing.T) {
+	g := newTestCommands(t)
+	defer os.RemoveAll(g.context.AbsPath)
+
+	// a pull that added /new, deleted /old and modified /mod
+	writeLocal(t, g, "/old", "old")
+	writeLocal(t, g, "/mod", "mine")
+	if err := g.moveToLocalTrash("/old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.moveToLocalTrash("/mod"); err != nil {
+		t.Fatal(err)
+	}
+	writeLocal(t, g, "/new", "new")
+	writeLocal(t, g, "/mod", "theirs")
+	g.record(cmdPull, OpAdd, "/new", "1", nil)
+	g.record(cmdPull, OpDelete, "/old", "", nil)
+	g.record(cmdPull, OpMod, "/mod", "2", nil)
+
+	g.newRun()
+	if err := g.Undo(); err != nil {
+		t.Fatalf("Undo() = %v", err)
+	}
+	tests := []struct{ path, want string }{
+		{"/new", "<missing>"},
+		{"/old", "old"},
+		{"/mod", "mine"},
+	}
+	for _, tt := range tests {
+		if got := readLocal(g, tt.path); got != tt.want {
+			t.Errorf("after the undo, %s holds %q; want %q", tt.path, got, tt.want)
+		}
+	}
+	if err := g.Undo(); err != ErrNothingToUndo {
+		t.Errorf("second Undo() = %v; want %v", err, ErrNothingToUndo)
+	}
+}
+
+func TestUndoLocalRestoresRemoved(t *testing.T) {
+	g := newTestCommands(t)
+	defer os.RemoveAll(g.context.AbsPath)
+
+	// rm moves the local copy into the trash entry of its run
+	writeLocal(t, g, "/dir/f", "content")
+	if err := g.moveToLocalTrash("/dir"); err != nil {
+		t.Fatal(err)
+	}
+	e := &JournalEntry{Run: g.runId, Command: cmdRm, Op: opNames[OpDelete], Path: "/dir"}
+
+	g.newRun()
+	if err := g.undoLocal(e); err != nil {
+		t.Fatalf("undoLocal() = %v", err)
+	}
+	if got := readLocal(g, "/dir/f"); got != "content" {
+		t.Errorf("after the undo, /dir/f holds %q; want %q", got, "content")
+	}
+}
+
+func TestUndoLocalCanBeRepeated(t *testing.T) {
+	g := newTestCommands(t)
+	defer os.RemoveAll(g.context.AbsPath)
+
+	writeLocal(t, g, "/old", "old")
+	writeLocal(t, g, "/mod", "mine")
+	if err := g.moveToLocalTrash("/old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.moveToLocalTrash("/mod"); err != nil {
+		t.Fatal(err)
+	}
+	writeLocal(t, g, "/new", "new")
+	writeLocal(t, g, "/mod", "theirs")
+	entries := []*JournalEntry{
+		{Run: g.runId, Command: cmdPull, Op: opNames[OpAdd], Path: "/new"},
+		{Run: g.runId, Command: cmdPull, Op: opNames[OpDelete], Path: "/old"},
+		{Run: g.runId, Command: cmdPull, Op: opNames[OpMod], Path: "/mod"},
+	}
+
+	// an undo that is interrupted and then run again
+	g.newRun()
+	for i := 0; i < 2; i++ {
+		for _, e := range entries {
+			if err := g.undoLocal(e); err != nil {
+				t.Fatalf("undoLocal(%s) #%d = %v", e.Path, i+1, err)
+			}
+		}
+	}
+	tests := []struct{ path, want string }{
+		{"/new", "<missing>"},
+		{"/old", "old"},
+		{"/mod", "mine"},
+	}
+	for _, tt := range tests {
+		if got := readLocal(g, tt.path); got != tt.want {
+			t.Errorf("after the repeated undo, %s holds %q; want %q", tt.path, got, tt.want)
+		}
+	}
+}
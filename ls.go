@@ -0,0 +1,180 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"text/tabwriter"
+)
+
+// Sort orders of the listings.
+const (
+	SortByName = "name"
+	SortBySize = "size"
+	SortByTime = "time"
+)
+
+// Sync state markers of the listed entries.
+const (
+	markInSync     = "="
+	markModified   = "M"
+	markRemoteOnly = "R"
+	markLocalOnly  = "L"
+)
+
+var ErrUnknownSort = errors.New("unknown sort order; use name, size or time")
+
+type lsEntries struct {
+	entries []*dirList
+	less    func(a, b *File) bool
+}
+
+func (s *lsEntries) Len() int      { return len(s.entries) }
+func (s *lsEntries) Swap(i, j int) { s.entries[i], s.entries[j] = s.entries[j], s.entries[i] }
+func (s *lsEntries) Less(i, j int) bool {
+	a, b := s.entries[i].file(), s.entries[j].file()
+	if s.less(a, b) {
+		return true
+	}
+	if s.less(b, a) {
+		return false
+	}
+	return a.Name < b.Name
+}
+
+// file returns the remote file of the entry, if any, otherwise the local
+// one.
+func (d *dirList) file() *File {
+	if d.remote != nil {
+		return d.remote
+	}
+	return d.local
+}
+
+// mark returns the sync state marker of the entry.
+func (d *dirList) mark() string {
+	switch {
+	case d.local == nil:
+		return markRemoteOnly
+	case d.remote == nil:
+		return markLocalOnly
+	case d.remote.IsDir && d.local.IsDir:
+		return markInSync
+	}
+	c := &Change{Src: d.remote, Dest: d.local}
+	if c.Op() != OpNone {
+		return markModified
+	}
+	return markInSync
+}
+
+// List lists the remote folder at the path, or the file itself, along
+// with the local-only entries of the working copy. Each entry is marked
+// as in sync (=), modified (M), remote-only (R) or local-only (L). The
+// long format adds the shared state, size, modification time, owner, id
+// and MD5 checksum of each entry.
+func (g *Commands) List(isLong bool, sortBy string, isReverse bool) (err error) {
+	var less func(a, b *File) bool
+	switch sortBy {
+	case "", SortByName:
+		less = func(a, b *File) bool { return a.Name < b.Name }
+	case SortBySize:
+		less = func(a, b *File) bool { return a.Size < b.Size }
+	case SortByTime:
+		less = func(a, b *File) bool { return a.ModTime.Before(b.ModTime) }
+	default:
+		return ErrUnknownSort
+	}
+	if isReverse {
+		asc := less
+		less = func(a, b *File) bool { return asc(b, a) }
+	}
+
+	entry := &dirList{}
+	if entry.remote, err = g.rem.FindByPath(g.opts.Path); err != nil && err != ErrPathNotExists {
+		return
+	}
+	absPath := g.context.AbsPathOf(g.opts.Path)
+	if info, e := os.Stat(absPath); e == nil {
+		entry.local = NewLocalFile(absPath, info)
+	}
+	if entry.remote == nil && entry.local == nil {
+		return ErrPathNotExists
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	defer w.Flush()
+	if !entry.file().IsDir {
+		g.printEntry(w, entry.file().Name, entry, isLong)
+		return
+	}
+	return g.listRecv(w, g.opts.Path, "", entry, less, isLong)
+}
+
+func (g *Commands) listRecv(w *tabwriter.Writer, p, rel string, dir *dirList, less func(a, b *File) bool, isLong bool) (err error) {
+	var remotes, locals []*File
+	if dir.remote != nil && dir.remote.IsDir {
+		if remotes, err = g.rem.FindByParentId(dir.remote.Id); err != nil {
+			return
+		}
+	}
+	if dir.local != nil && dir.local.IsDir {
+		if locals, err = list(g.context, p, g.opts.Hidden); err != nil {
+			return
+		}
+	}
+	entries := &lsEntries{entries: merge(remotes, locals), less: less}
+	sort.Sort(entries)
+	for _, e := range entries.entries {
+		name := path.Join(rel, e.Name())
+		g.printEntry(w, name, e, isLong)
+		if g.opts.IsRecursive && e.file().IsDir {
+			if err = g.listRecv(w, path.Join(p, e.Name()), name, e, less, isLong); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (g *Commands) printEntry(w *tabwriter.Writer, name string, e *dirList, isLong bool) {
+	f := e.file()
+	if f.IsDir {
+		name += "/"
+	}
+	if !isLong {
+		fmt.Fprintf(w, "%s %s\n", e.mark(), name)
+		return
+	}
+	shared, owner, id, sum := "-", "-", "-", md5Checksum(f)
+	if e.remote != nil {
+		if e.remote.Shared {
+			shared = "s"
+		}
+		if len(e.remote.Owners) > 0 {
+			owner = e.remote.Owners[0].Email
+		}
+		id = e.remote.Id
+	}
+	if sum == "" {
+		sum = "-"
+	}
+	fmt.Fprintf(w, "%s %s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+		e.mark(), shared, f.Size, f.ModTime.Format("2006-01-02 15:04"), owner, id, sum, name)
+}
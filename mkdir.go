@@ -0,0 +1,71 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Mkdir creates the remote folder at Path, and the local one so that
+// the next push doesn't delete it. If isParents is set, missing parents
+// are created and an existing folder is not an error. The local folder
+// isn't created if it is outside of the sparse checkout.
+func (g *Commands) Mkdir(isParents bool) (err error) {
+	if g.opts.Path == "/" {
+		if isParents {
+			return nil
+		}
+		return ErrPathExists
+	}
+	if g.sparse, err = g.context.ReadSparse(); err != nil {
+		return
+	}
+	var parent *File
+	if parent, err = g.rem.FindByPath("/"); err != nil {
+		return
+	}
+	parts := strings.Split(strings.Trim(g.opts.Path, "/"), "/")
+	p := "/"
+	for i, name := range parts {
+		p = path.Join(p, name)
+		isLast := i == len(parts)-1
+		f, e := g.rem.FindByPath(p)
+		switch {
+		case e == nil && !f.IsDir:
+			return fmt.Errorf("%s is not a folder", p)
+		case e == nil && isLast && !isParents:
+			return ErrPathExists
+		case e == nil:
+			parent = f
+			continue
+		case e != ErrPathNotExists:
+			return e
+		case !isLast && !isParents:
+			return fmt.Errorf("%s doesn't exist; use -p to create it", p)
+		}
+		if parent, err = g.rem.Upsert(parent.Id, &File{Name: name, IsDir: true}, nil, false); err != nil {
+			return
+		}
+		g.record(cmdMkdir, OpAdd, p, parent.Id, nil)
+		fmt.Println("Created", p)
+	}
+	if !g.isSparseIncluded(g.opts.Path) {
+		return
+	}
+	return os.MkdirAll(g.context.AbsPathOf(g.opts.Path), os.ModeDir|0755)
+}
@@ -0,0 +1,238 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrPathExists     = errors.New("destination path already exists")
+	ErrParentNotDir   = errors.New("destination's parent is not a folder")
+	ErrMoveIntoSelf   = errors.New("cannot move or copy a folder into itself")
+	ErrCannotMoveRoot = errors.New("cannot move or copy the context root")
+)
+
+// Move moves or renames the remote file at Path to dest, without
+// transferring its content, and does the same to the local copy. If dest
+// is an existing folder, the file is moved into it. Sparse patterns
+// under the moved path are updated to follow it.
+func (g *Commands) Move(dest string) (err error) {
+	var src *File
+	var parentId, target string
+	if src, parentId, target, err = g.resolveMove(dest); err != nil {
+		return
+	}
+	var oldParent string
+	if oldParent, err = g.parentIdOf(g.opts.Path); err != nil {
+		return
+	}
+	if err = g.rem.Move(src.Id, oldParent, parentId, path.Base(target)); err != nil {
+		return
+	}
+	g.recordMove(g.opts.Path, target, src.Id)
+	fmt.Println("Moved", g.opts.Path, "to", target)
+	if err = g.moveLocal(g.opts.Path, target); err != nil {
+		return
+	}
+	return g.moveSparse(g.opts.Path, target)
+}
+
+// moveLocal moves the local copy at src to dest, if there is one.
+func (g *Commands) moveLocal(src, dest string) (err error) {
+	srcAbsPath := g.context.AbsPathOf(src)
+	if _, e := os.Lstat(srcAbsPath); e != nil {
+		return
+	}
+	destAbsPath := g.context.AbsPathOf(dest)
+	if err = os.MkdirAll(filepath.Dir(destAbsPath), os.ModeDir|0755); err != nil {
+		return
+	}
+	return os.Rename(srcAbsPath, destAbsPath)
+}
+
+// Copy copies the remote file or folder at Path to dest with the files
+// copy endpoint of Drive, so that the content isn't transferred. Local
+// copies that are in sync are copied too.
+func (g *Commands) Copy(dest string) (err error) {
+	var src *File
+	var parentId, target string
+	if src, parentId, target, err = g.resolveMove(dest); err != nil {
+		return
+	}
+	var id string
+	id, err = g.copyRecv(g.opts.Path, target, src, parentId)
+	if id != "" {
+		// undoing trashes the whole copy, even if it is incomplete
+		g.record(cmdCp, OpAdd, target, id, nil)
+	}
+	if err != nil {
+		return
+	}
+	fmt.Println("Copied", g.opts.Path, "to", target)
+	return
+}
+
+// resolveMove finds the source file and the parent id and the path of
+// the destination of a move or a copy. The destination must exist
+// neither remotely nor locally.
+func (g *Commands) resolveMove(dest string) (src *File, parentId, target string, err error) {
+	if g.opts.Path == "/" {
+		err = ErrCannotMoveRoot
+		return
+	}
+	if src, err = g.rem.FindByPath(g.opts.Path); err != nil {
+		return
+	}
+	target = path.Clean(path.Join("/", dest))
+	d, e := g.rem.FindByPath(target)
+	if e == nil && d.IsDir {
+		parentId = d.Id
+		target = path.Join(target, src.Name)
+		_, e = g.rem.FindByPath(target)
+	}
+	switch {
+	case e == nil:
+		err = ErrPathExists
+		return
+	case e != ErrPathNotExists:
+		err = e
+		return
+	}
+	if target == g.opts.Path || strings.HasPrefix(target, g.opts.Path+"/") {
+		err = ErrMoveIntoSelf
+		return
+	}
+	if _, e = os.Lstat(g.context.AbsPathOf(target)); e == nil {
+		err = ErrPathExists
+		return
+	} else if !os.IsNotExist(e) {
+		err = e
+		return
+	}
+	if parentId == "" {
+		if d, err = g.rem.FindByPath(path.Dir(target)); err != nil {
+			return
+		}
+		if !d.IsDir {
+			err = ErrParentNotDir
+			return
+		}
+		parentId = d.Id
+	}
+	return
+}
+
+func (g *Commands) parentIdOf(p string) (string, error) {
+	parent, err := g.rem.FindByPath(path.Dir(p))
+	if err != nil {
+		return "", err
+	}
+	return parent.Id, nil
+}
+
+// copyRecv copies src and, if it is a folder, its descendants. It
+// returns the id of the remote copy of src, once it has been made.
+func (g *Commands) copyRecv(srcPath, destPath string, src *File, parentId string) (id string, err error) {
+	srcAbsPath, destAbsPath := g.context.AbsPathOf(srcPath), g.context.AbsPathOf(destPath)
+	var l *File
+	if info, e := os.Stat(srcAbsPath); e == nil {
+		l = NewLocalFile(srcAbsPath, info)
+	}
+	if !src.IsDir {
+		var copied *File
+		if copied, err = g.rem.Copy(src.Id, parentId, path.Base(destPath)); err != nil {
+			return
+		}
+		id = copied.Id
+		if l == nil || l.IsDir || (&Change{Src: src, Dest: l}).Op() != OpNone {
+			// pull will download it
+			return
+		}
+		if err = copyLocalFile(srcAbsPath, destAbsPath); err != nil {
+			return
+		}
+		err = os.Chtimes(destAbsPath, copied.ModTime, copied.ModTime)
+		return
+	}
+
+	var dir *File
+	if dir, err = g.rem.Upsert(parentId, &File{Name: path.Base(destPath), IsDir: true}, nil, false); err != nil {
+		return
+	}
+	id = dir.Id
+	if l != nil && l.IsDir {
+		if err = os.MkdirAll(destAbsPath, os.ModeDir|0755); err != nil {
+			return
+		}
+	}
+	var children []*File
+	// a copy is complete, hidden files included
+	if children, err = g.rem.FindAllByParentId(src.Id); err != nil {
+		return
+	}
+	for _, c := range children {
+		if _, err = g.copyRecv(path.Join(srcPath, c.Name), path.Join(destPath, c.Name), c, dir.Id); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// copyLocalFile copies the file at src to dest, which must not exist,
+// creating dest's parents if necessary.
+func copyLocalFile(src, dest string) (err error) {
+	var in, out *os.File
+	if in, err = os.Open(src); err != nil {
+		return
+	}
+	defer in.Close()
+	if err = os.MkdirAll(filepath.Dir(dest), os.ModeDir|0755); err != nil {
+		return
+	}
+	if out, err = os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err != nil {
+		return
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return
+	}
+	return out.Close()
+}
+
+// moveSparse rewrites the sparse patterns under src to be under dest.
+func (g *Commands) moveSparse(src, dest string) (err error) {
+	var patterns []string
+	if patterns, err = g.context.ReadSparse(); err != nil || len(patterns) == 0 {
+		return
+	}
+	changed := false
+	for i, p := range patterns {
+		if p == src || strings.HasPrefix(p, src+"/") {
+			patterns[i] = dest + strings.TrimPrefix(p, src)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	return g.context.WriteSparse(patterns)
+}
@@ -0,0 +1,53 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyLocalFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	if err = ioutil.WriteFile(src, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, "a", "b", "dest")
+	if err = copyLocalFile(src, dest); err != nil {
+		t.Fatalf("copyLocalFile() = %v", err)
+	}
+	if data, _ := ioutil.ReadFile(dest); string(data) != "content" {
+		t.Errorf("the copy holds %q; want %q", data, "content")
+	}
+
+	// an existing file is never overwritten
+	if err = ioutil.WriteFile(dest, []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = copyLocalFile(src, dest); err == nil {
+		t.Errorf("copyLocalFile() over an existing file succeeded")
+	}
+	if data, _ := ioutil.ReadFile(dest); string(data) != "local" {
+		t.Errorf("the existing file holds %q; want %q", data, "local")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rakyll/drive/config"
+)
+
+var (
+	ErrNoProfileName = errors.New("no profile name is given")
+)
+
+// ProfileList prints the names of the user's profiles.
+func (g *Commands) ProfileList() (err error) {
+	var names []string
+	if names, err = config.ListProfiles(); err != nil {
+		return
+	}
+	if len(names) == 0 {
+		fmt.Println("No profiles; use gd profile add <name>.")
+		return
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return
+}
+
+// ProfileAdd authorizes an account and saves its credentials as a
+// profile, replacing the profile if it exists.
+func (g *Commands) ProfileAdd() (err error) {
+	if g.opts.Profile == "" {
+		return ErrNoProfileName
+	}
+	if err = config.ValidateProfileName(g.opts.Profile); err != nil {
+		return
+	}
+	creds := &config.Credentials{}
+	if err = g.authorize(creds); err != nil {
+		return
+	}
+	return config.WriteProfile(g.opts.Profile, creds)
+}
+
+// ProfileRemove removes a profile and its secrets. Contexts that are
+// bound to the profile need to be initialized again.
+func (g *Commands) ProfileRemove() error {
+	if g.opts.Profile == "" {
+		return ErrNoProfileName
+	}
+	return config.RemoveProfile(g.opts.Profile)
+}
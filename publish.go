@@ -18,23 +18,63 @@ import (
 	"fmt"
 )
 
-func (c *Commands) Publish() (err error) {
+// Publication describes how a file is published.
+type Publication struct {
+	// IsPublic makes the file public on the web, otherwise only the
+	// ones with the link can access it
+	IsPublic bool
+	// AllowDownload lets the viewers download, print and copy the file
+	AllowDownload bool
+}
+
+func (c *Commands) Publish(pub *Publication) (err error) {
 	var file *File
-	var link string
 	if file, err = c.rem.FindByPath(c.opts.Path); err != nil {
 		return
 	}
-	if link, err = c.rem.Publish(file.Id); err != nil {
+	// restrict the downloads before anyone can access the file
+	if err = c.rem.SetRestricted(file.Id, !pub.AllowDownload); err != nil {
+		return
+	}
+	perm := &Permission{
+		Type:     GranteeAnyone,
+		Role:     "reader",
+		WithLink: !pub.IsPublic,
+	}
+	if err = c.rem.Share(file.Id, perm, false, ""); err != nil {
+		return
+	}
+	// the links are only known once the file is published
+	if file, err = c.rem.FindById(file.Id); err != nil {
 		return
 	}
-	fmt.Println("Published on", link)
+	fmt.Println("Published", c.opts.Path)
+	if file.WebViewLink != "" {
+		fmt.Println("View:    ", file.WebViewLink)
+	}
+	if file.WebContentLink != "" && pub.AllowDownload {
+		fmt.Println("Download:", file.WebContentLink)
+	}
 	return
 }
 
+// Unpublish removes the access of anyone, with or without the link.
 func (c *Commands) Unpublish() error {
 	file, err := c.rem.FindByPath(c.opts.Path)
 	if err != nil {
 		return err
 	}
-	return c.rem.Unpublish(file.Id)
+	perms, err := c.rem.FindPermissions(file.Id)
+	if err != nil {
+		return err
+	}
+	for _, p := range perms {
+		if p.Type != GranteeAnyone {
+			continue
+		}
+		if err = c.rem.Unshare(file.Id, p.Id); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -41,12 +41,16 @@ func (g *Commands) Pull() (err error) {
 	}
 
 	var cl []*Change
+	if g.sparse, err = g.context.ReadSparse(); err != nil {
+		return
+	}
 	fmt.Println("Resolving...")
 	if cl, err = g.resolveChangeListRecv(false, g.opts.Path, r, l); err != nil {
 		return
 	}
 
-	if ok := printChangeList(cl, g.opts.IsNoPrompt); ok {
+	var ok bool
+	if cl, ok, err = g.selectChangeList(cl); ok {
 		return g.playPullChangeList(cl)
 	}
 	return
@@ -56,9 +60,13 @@ func (g *Commands) playPullChangeList(cl []*Change) (err error) {
 	var next []*Change
 	g.taskStart(len(cl))
 
+	n := g.opts.Concurrency
+	if n <= 0 {
+		n = maxNumOfConcPullTasks
+	}
 	for {
-		if len(cl) > maxNumOfConcPullTasks {
-			next, cl = cl[:maxNumOfConcPullTasks], cl[maxNumOfConcPullTasks:len(cl)]
+		if len(cl) > n {
+			next, cl = cl[:n], cl[n:len(cl)]
 		} else {
 			next, cl = cl, []*Change{}
 		}
@@ -83,7 +91,11 @@ func (g *Commands) playPullChangeList(cl []*Change) (err error) {
 	}
 
 	g.taskFinish()
-	return err
+	retention := g.opts.TrashRetention
+	if retention == 0 {
+		retention = DefaultLocalTrashRetention
+	}
+	return g.LocalTrashPurge(retention)
 }
 
 func (g *Commands) localMod(wg *sync.WaitGroup, change *Change) (err error) {
@@ -91,10 +103,14 @@ func (g *Commands) localMod(wg *sync.WaitGroup, change *Change) (err error) {
 	defer wg.Done()
 	destAbsPath := g.context.AbsPathOf(change.Path)
 	if change.Src.BlobAt != "" {
-		// download and replace
+		// keep the local copy in the trash, then download and replace
+		if err = g.moveToLocalTrash(change.Path); err != nil {
+			return
+		}
 		if err = g.download(change); err != nil {
 			return
 		}
+		g.record(cmdPull, OpMod, change.Path, change.Src.Id, nil)
 	}
 	return os.Chtimes(destAbsPath, change.Src.ModTime, change.Src.ModTime)
 }
@@ -106,7 +122,11 @@ func (g *Commands) localAdd(wg *sync.WaitGroup, change *Change) (err error) {
 	// make parent's dir if not exists
 	os.MkdirAll(filepath.Dir(destAbsPath), os.ModeDir|0755)
 	if change.Src.IsDir {
-		return os.Mkdir(destAbsPath, os.ModeDir|0755)
+		if err = os.Mkdir(destAbsPath, os.ModeDir|0755); err != nil {
+			return
+		}
+		g.record(cmdPull, OpAdd, change.Path, change.Src.Id, nil)
+		return
 	}
 	if change.Src.BlobAt != "" {
 		// download and create
@@ -114,13 +134,18 @@ func (g *Commands) localAdd(wg *sync.WaitGroup, change *Change) (err error) {
 			return
 		}
 	}
+	g.record(cmdPull, OpAdd, change.Path, change.Src.Id, nil)
 	return os.Chtimes(destAbsPath, change.Src.ModTime, change.Src.ModTime)
 }
 
 func (g *Commands) localDelete(wg *sync.WaitGroup, change *Change) (err error) {
 	defer g.taskDone()
 	defer wg.Done()
-	return os.RemoveAll(change.Dest.BlobAt)
+	if err = g.moveToLocalTrash(change.Path); err != nil {
+		return
+	}
+	g.record(cmdPull, OpDelete, change.Path, "", nil)
+	return
 }
 
 func (g *Commands) download(change *Change) (err error) {
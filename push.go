@@ -40,13 +40,17 @@ func (g *Commands) Push() (err error) {
 		l = NewLocalFile(absPath, localinfo)
 	}
 
+	if g.sparse, err = g.context.ReadSparse(); err != nil {
+		return
+	}
 	fmt.Println("Resolving...")
 	var cl []*Change
 	if cl, err = g.resolveChangeListRecv(true, g.opts.Path, r, l); err != nil {
 		return err
 	}
 
-	if ok := printChangeList(cl, g.opts.IsNoPrompt); ok {
+	var ok bool
+	if cl, ok, err = g.selectChangeList(cl); ok {
 		return g.playPushChangeList(cl)
 	}
 	return
@@ -54,14 +58,19 @@ func (g *Commands) Push() (err error) {
 
 func (g *Commands) playPushChangeList(cl []*Change) (err error) {
 	g.taskStart(len(cl))
+	// keep applying the remaining changes, but report the first failure
 	for _, c := range cl {
+		var e error
 		switch c.Op() {
 		case OpMod:
-			g.remoteMod(c)
+			e = g.remoteMod(c)
 		case OpAdd:
-			g.remoteAdd(c)
+			e = g.remoteAdd(c)
 		case OpDelete:
-			g.remoteDelete(c)
+			e = g.remoteDelete(c)
+		}
+		if e != nil && err == nil {
+			err = e
 		}
 	}
 	g.taskFinish()
@@ -89,9 +98,14 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 			return err
 		}
 	}
-	if updated, err = g.rem.Upsert(parent.Id, change.Src, body); err != nil {
+	if updated, err = g.rem.Upsert(parent.Id, change.Src, body, g.opts.KeepForever); err != nil {
 		return
 	}
+	if change.Dest == nil {
+		g.record(cmdPush, OpAdd, change.Path, updated.Id, nil)
+	} else {
+		g.record(cmdPush, OpMod, change.Path, updated.Id, change.Dest)
+	}
 	return os.Chtimes(absPath, updated.ModTime, updated.ModTime)
 }
 
@@ -101,7 +115,11 @@ func (g *Commands) remoteAdd(change *Change) (err error) {
 
 func (g *Commands) remoteDelete(change *Change) (err error) {
 	defer g.taskDone()
-	return g.rem.Trash(change.Dest.Id)
+	if err = g.rem.Trash(change.Dest.Id); err != nil {
+		return
+	}
+	g.record(cmdPush, OpDelete, change.Path, change.Dest.Id, change.Dest)
+	return
 }
 
 func list(context *config.Context, path string, hidden bool) (files []*File, err error) {
@@ -111,6 +129,10 @@ func list(context *config.Context, path string, hidden bool) (files []*File, err
 		return
 	}
 	for _, file := range f {
+		// never sync the gd directory itself
+		if path == "/" && file.Name() == ".gd" {
+			continue
+		}
 		if hidden || !strings.HasPrefix(file.Name(), ".") {
 			files = append(files, NewLocalFile(gopath.Join(absPath, file.Name()), file))
 		}
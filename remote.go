@@ -19,12 +19,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"path"
 	"strings"
 	"time"
 
 	"code.google.com/p/goauth2/oauth"
-	drive "code.google.com/p/google-api-go-client/drive/v2"
 	"github.com/rakyll/drive/config"
+	drive "google.golang.org/api/drive/v2"
 )
 
 const (
@@ -32,9 +33,6 @@ const (
 	GoogleOAuth2AuthURL  = "https://accounts.google.com/o/oauth2/auth"
 	GoogleOAuth2TokenURL = "https://accounts.google.com/o/oauth2/token"
 
-	// OAuth 2.0 OOB redirect URL for authorization.
-	RedirectURL = "urn:ietf:wg:oauth:2.0:oob"
-
 	// OAuth 2.0 full Drive scope used for authorization.
 	DriveScope = "https://www.googleapis.com/auth/drive"
 
@@ -42,38 +40,116 @@ const (
 	AccessType = "offline"
 )
 
+const (
+	// Alias of the My Drive root folder.
+	myDriveRootId = "root"
+
+	// Prefix of the root paths that start among the files shared with
+	// the user instead of My Drive.
+	sharedWithMePrefix = "shared-with-me/"
+)
+
 var (
-	ErrPathNotExists = errors.New("remote path doesn't exist")
+	ErrPathNotExists     = errors.New("remote path doesn't exist")
+	ErrRootNotDir        = errors.New("remote root is not a folder")
+	ErrDriveNotExists    = errors.New("shared drive doesn't exist")
+	ErrSharedRootInDrive = errors.New("a shared-with-me root can't be used with a shared drive")
 )
 
 type Remote struct {
-	transport *oauth.Transport
-	service   *drive.Service
+	client  *http.Client
+	service *drive.Service
+	// rootId is the id of the folder the context is mapped to.
+	rootId string
+	// driveId is the id of the shared drive the context is bound to.
+	driveId string
 }
 
 func NewRemoteContext(context *config.Context) *Remote {
-	transport := newTransport(context)
-	service, _ := drive.New(transport.Client())
-	return &Remote{service: service, transport: transport}
-}
-
-func RetrieveRefreshToken(context *config.Context) (string, error) {
-	transport := newTransport(context)
-	url := transport.Config.AuthCodeURL("")
-	fmt.Println("Visit this URL to get an authorization code")
-	fmt.Println(url)
-	fmt.Print("Paste the authorization code: ")
-	var code string
-	fmt.Scanln(&code)
-	token, err := transport.Exchange(code)
+	client := &http.Client{Transport: newTransport(context)}
+	service, _ := drive.New(client)
+	rootId := context.RootId
+	switch {
+	case rootId != "":
+	case context.DriveId != "":
+		rootId = context.DriveId
+	default:
+		rootId = myDriveRootId
+	}
+	return &Remote{service: service, client: client, rootId: rootId, driveId: context.DriveId}
+}
+
+// FindDrives lists the shared drives available to the user.
+func (r *Remote) FindDrives() (drives []*Drive, err error) {
+	req := r.service.Drives.List()
+	for {
+		var results *drive.DriveList
+		if results, err = req.Do(); err != nil {
+			return
+		}
+		for _, d := range results.Items {
+			drives = append(drives, &Drive{Id: d.Id, Name: d.Name})
+		}
+		if results.NextPageToken == "" {
+			return
+		}
+		req.PageToken(results.NextPageToken)
+	}
+}
+
+// ResolveDrive returns the id of the shared drive with the given name
+// or id.
+func (r *Remote) ResolveDrive(nameOrId string) (string, error) {
+	drives, err := r.FindDrives()
 	if err != nil {
 		return "", err
 	}
-	return token.RefreshToken, nil
+	for _, d := range drives {
+		if d.Id == nameOrId || d.Name == nameOrId {
+			return d.Id, nil
+		}
+	}
+	return "", ErrDriveNotExists
+}
+
+// ResolveRoot returns the id of the folder described by root, which is
+// either a path in My Drive (or in the shared drive the context is bound
+// to) such as /Projects/gd, a path that starts with one of the folders
+// shared with the user such as shared-with-me/Team/docs, or a folder id.
+func (r *Remote) ResolveRoot(root string) (id string, err error) {
+	driveRootId := myDriveRootId
+	if r.driveId != "" {
+		driveRootId = r.driveId
+	}
+	var file *File
+	switch {
+	case strings.HasPrefix(root, "/"):
+		if root = path.Clean(root); root == "/" {
+			return driveRootId, nil
+		}
+		file, err = r.findByPathRecv(driveRootId, strings.Split(root, "/")[1:])
+	case strings.HasPrefix(root, sharedWithMePrefix):
+		if r.driveId != "" {
+			return "", ErrSharedRootInDrive
+		}
+		parts := strings.Split(path.Clean(strings.TrimPrefix(root, sharedWithMePrefix)), "/")
+		if file, err = r.findSharedWithMe(parts[0]); err == nil && len(parts) > 1 {
+			file, err = r.findByPathRecv(file.Id, parts[1:])
+		}
+	default:
+		file, err = r.FindById(root)
+	}
+	if err != nil {
+		return
+	}
+	if !file.IsDir {
+		return "", ErrRootNotDir
+	}
+	return file.Id, nil
 }
 
 func (r *Remote) FindById(id string) (file *File, err error) {
-	req := r.service.Files.Get(id)
+	req := r.service.Files.Get(id).SupportsAllDrives(true)
 	var f *drive.File
 	if f, err = req.Do(); err != nil {
 		return
@@ -83,68 +159,314 @@ func (r *Remote) FindById(id string) (file *File, err error) {
 
 func (r *Remote) FindByPath(p string) (file *File, err error) {
 	if p == "/" {
-		return r.FindById("root")
+		return r.FindById(r.rootId)
 	}
 	parts := strings.Split(p, "/") // TODO: use path.Split instead
-	return r.findByPathRecv("root", parts[1:])
+	return r.findByPathRecv(r.rootId, parts[1:])
 }
 
 func (r *Remote) FindByParentId(parentId string) (files []*File, err error) {
-	req := r.service.Files.List()
+	return r.findByParentId(parentId, false)
+}
+
+// FindAllByParentId lists the children of the folder, including the
+// hidden ones.
+func (r *Remote) FindAllByParentId(parentId string) (files []*File, err error) {
+	return r.findByParentId(parentId, true)
+}
+
+func (r *Remote) findByParentId(parentId string, hidden bool) (files []*File, err error) {
 	// TODO: use field selectors
-	req.Q(fmt.Sprintf("'%s' in parents and trashed=false", parentId))
-	results, err := req.Do()
-	// TODO: handle paging
-	if err != nil {
-		return
-	}
-	for _, f := range results.Items {
-		if !strings.HasPrefix(f.Title, ".") { // ignore hidden files
-			files = append(files, NewRemoteFile(f))
+	req := r.list(fmt.Sprintf("'%s' in parents and trashed=false", parentId))
+	for {
+		var results *drive.FileList
+		if results, err = req.Do(); err != nil {
+			return
 		}
+		for _, f := range results.Items {
+			if hidden || !strings.HasPrefix(f.Title, ".") {
+				files = append(files, NewRemoteFile(f))
+			}
+		}
+		if results.NextPageToken == "" {
+			return
+		}
+		req.PageToken(results.NextPageToken)
 	}
-	return
 }
 
 func (r *Remote) Trash(id string) error {
-	_, err := r.service.Files.Trash(id).Do()
+	_, err := r.service.Files.Trash(id).SupportsAllDrives(true).Do()
+	return err
+}
+
+func (r *Remote) Untrash(id string) error {
+	_, err := r.service.Files.Untrash(id).SupportsAllDrives(true).Do()
 	return err
 }
 
-func (r *Remote) Unpublish(id string) error {
-	return r.service.Permissions.Delete(id, "anyone").Do()
+// Delete permanently deletes the file, skipping the trash.
+func (r *Remote) Delete(id string) error {
+	return r.service.Files.Delete(id).SupportsAllDrives(true).Do()
 }
 
-func (r *Remote) Publish(id string) (string, error) {
-	perm := &drive.Permission{Type: "anyone", Role: "reader"}
-	_, err := r.service.Permissions.Insert(id, perm).Do()
+// EmptyTrash permanently deletes all of the files in the trash. In a
+// shared drive, or if the context is mapped to a folder, the trashed
+// files of the context are deleted one by one.
+func (r *Remote) EmptyTrash() error {
+	if r.driveId == "" && !r.isMappedToFolder() {
+		return r.service.Files.EmptyTrash().Do()
+	}
+	files, err := r.FindTrashed()
 	if err != nil {
-		return "", err
+		return err
+	}
+	for _, f := range files {
+		if err = r.Delete(f.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindTrashed lists the explicitly trashed files along with the paths
+// they had before they were trashed. Files whose original location
+// can't be resolved have an empty path, unless the context is mapped to
+// a folder, in which case only the files trashed from within it are
+// listed.
+func (r *Remote) FindTrashed() (files []*FoundFile, err error) {
+	dirs := make(map[string]string)
+	req := r.list("trashed=true")
+	for {
+		var results *drive.FileList
+		if results, err = req.Do(); err != nil {
+			return
+		}
+		for _, f := range results.Items {
+			if !f.ExplicitlyTrashed {
+				continue
+			}
+			p, e := r.pathOf(f, dirs)
+			if e != nil && r.isMappedToFolder() {
+				continue
+			}
+			files = append(files, &FoundFile{File: NewRemoteFile(f), Path: p})
+		}
+		if results.NextPageToken == "" {
+			return
+		}
+		req.PageToken(results.NextPageToken)
+	}
+}
+
+// FindByQuery lists the files matching the Drive query that are within
+// the context root, along with their paths.
+func (r *Remote) FindByQuery(q string) (files []*FoundFile, err error) {
+	dirs := make(map[string]string)
+	req := r.list(q)
+	for {
+		var results *drive.FileList
+		if results, err = req.Do(); err != nil {
+			return
+		}
+		for _, f := range results.Items {
+			if p, e := r.pathOf(f, dirs); e == nil {
+				files = append(files, &FoundFile{File: NewRemoteFile(f), Path: p})
+			}
+		}
+		if results.NextPageToken == "" {
+			return
+		}
+		req.PageToken(results.NextPageToken)
+	}
+}
+
+// FindPermissions returns the permissions of the file.
+func (r *Remote) FindPermissions(id string) (perms []*Permission, err error) {
+	req := r.service.Permissions.List(id).SupportsAllDrives(true)
+	for {
+		var list *drive.PermissionList
+		if list, err = req.Do(); err != nil {
+			return
+		}
+		for _, p := range list.Items {
+			perms = append(perms, newPermission(p))
+		}
+		if list.NextPageToken == "" {
+			return
+		}
+		req.PageToken(list.NextPageToken)
+	}
+}
+
+// Share inserts the permission, sending notification emails with the
+// message to the grantee if notify is set.
+func (r *Remote) Share(id string, p *Permission, notify bool, message string) error {
+	perm := &drive.Permission{Type: p.Type, Role: p.Role, WithLink: p.WithLink}
+	if p.Expiration != nil {
+		perm.ExpirationDate = p.Expiration.UTC().Format(time.RFC3339)
+	}
+	if p.Role == "commenter" {
+		perm.Role, perm.AdditionalRoles = "reader", []string{"commenter"}
+	}
+	switch p.Type {
+	case GranteeUser, GranteeGroup:
+		perm.Value = p.Email
+	case GranteeDomain:
+		perm.Value = p.Domain
 	}
-	return "https://googledrive.com/host/" + id, nil
+	req := r.service.Permissions.Insert(id, perm).SupportsAllDrives(true)
+	if p.Type == GranteeUser || p.Type == GranteeGroup {
+		req.SendNotificationEmails(notify)
+		if notify && message != "" {
+			req.EmailMessage(message)
+		}
+	}
+	_, err := req.Do()
+	return err
+}
+
+// Unshare deletes the permission.
+func (r *Remote) Unshare(id, permissionId string) error {
+	return r.service.Permissions.Delete(id, permissionId).SupportsAllDrives(true).Do()
+}
+
+// SetRestricted sets whether the viewers of the file are prevented
+// from downloading, printing and copying it. The label is always sent,
+// since the client library would otherwise drop a false one.
+func (r *Remote) SetRestricted(id string, restricted bool) error {
+	labels := &drive.FileLabels{Restricted: restricted, ForceSendFields: []string{"Restricted"}}
+	_, err := r.service.Files.Patch(id, &drive.File{Labels: labels}).SupportsAllDrives(true).Do()
+	return err
+}
+
+// Download returns the content of the file. Native Google documents
+// have no content of their own and need to be exported.
+func (r *Remote) Download(id string) (body io.ReadCloser, err error) {
+	var f *drive.File
+	if f, err = r.service.Files.Get(id).SupportsAllDrives(true).Do(); err != nil {
+		return
+	}
+	if f.DownloadUrl == "" {
+		return nil, fmt.Errorf("%s can't be downloaded, only exported", f.Title)
+	}
+	return r.download(f.DownloadUrl)
 }
 
-func (r *Remote) Download(id string) (io.ReadCloser, error) {
-	resp, err := r.transport.Client().Get("https://googledrive.com/host/" + id)
-	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return resp.Body, err
+// Export returns the content of the native Google document converted to
+// the MIME type.
+func (r *Remote) Export(id, mimeType string) (body io.ReadCloser, err error) {
+	var f *drive.File
+	if f, err = r.service.Files.Get(id).SupportsAllDrives(true).Do(); err != nil {
+		return
+	}
+	u, ok := f.ExportLinks[mimeType]
+	if !ok {
+		return nil, fmt.Errorf("%s can't be exported to %s", f.Title, mimeType)
+	}
+	return r.download(u)
+}
+
+func (r *Remote) download(u string) (io.ReadCloser, error) {
+	resp, err := r.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("downloading %s: %s", u, resp.Status)
 	}
 	return resp.Body, nil
 }
 
-func (r *Remote) Upsert(parentId string, file *File, body io.Reader) (f *File, err error) {
+// FindRevisions lists the revisions of the file, oldest first.
+func (r *Remote) FindRevisions(id string) (revs []*Revision, err error) {
+	req := r.service.Revisions.List(id)
+	for {
+		var list *drive.RevisionList
+		if list, err = req.Do(); err != nil {
+			return
+		}
+		for _, rev := range list.Items {
+			revs = append(revs, newRevision(rev))
+		}
+		if list.NextPageToken == "" {
+			return
+		}
+		req.PageToken(list.NextPageToken)
+	}
+}
+
+func (r *Remote) FindRevision(id, revisionId string) (*Revision, error) {
+	rev, err := r.service.Revisions.Get(id, revisionId).Do()
+	if err != nil {
+		return nil, err
+	}
+	return newRevision(rev), nil
+}
+
+// DownloadRevision returns the content of the given revision.
+func (r *Remote) DownloadRevision(id, revisionId string) (body io.ReadCloser, err error) {
+	var rev *drive.Revision
+	if rev, err = r.service.Revisions.Get(id, revisionId).Do(); err != nil {
+		return
+	}
+	if rev.DownloadUrl == "" {
+		return nil, fmt.Errorf("revision %s of %s can't be downloaded", revisionId, id)
+	}
+	return r.download(rev.DownloadUrl)
+}
+
+// Revert uploads the content of the given revision as the new head
+// revision of the file.
+func (r *Remote) Revert(id, revisionId string) (err error) {
+	var body io.ReadCloser
+	if body, err = r.DownloadRevision(id, revisionId); err != nil {
+		return
+	}
+	defer body.Close()
+	_, err = r.service.Files.Update(id, &drive.File{}).SupportsAllDrives(true).Media(body).Do()
+	return
+}
+
+// Move renames the file and moves it from the old parent to the new
+// one with a metadata patch.
+func (r *Remote) Move(id, oldParentId, newParentId, title string) error {
+	req := r.service.Files.Patch(id, &drive.File{Title: title}).SupportsAllDrives(true)
+	if oldParentId != newParentId {
+		req.AddParents(newParentId).RemoveParents(oldParentId)
+	}
+	_, err := req.Do()
+	return err
+}
+
+// Copy copies the file into the parent under the title.
+func (r *Remote) Copy(id, parentId, title string) (file *File, err error) {
+	copied := &drive.File{
+		Title:   title,
+		Parents: []*drive.ParentReference{&drive.ParentReference{Id: parentId}},
+	}
+	if copied, err = r.service.Files.Copy(id, copied).SupportsAllDrives(true).Do(); err != nil {
+		return
+	}
+	return NewRemoteFile(copied), nil
+}
+
+// Upsert creates or updates the file under the parent. If pinned is
+// set, the uploaded revision is kept forever.
+func (r *Remote) Upsert(parentId string, file *File, body io.Reader, pinned bool) (f *File, err error) {
 	uploaded := &drive.File{
 		Title:   file.Name,
 		Parents: []*drive.ParentReference{&drive.ParentReference{Id: parentId}},
 	}
 	if file.IsDir {
-		uploaded.MimeType = "application/vnd.google-apps.folder"
+		uploaded.MimeType = folderMimeType
 	}
 
 	if file.Id == "" {
-		req := r.service.Files.Insert(uploaded)
+		req := r.service.Files.Insert(uploaded).SupportsAllDrives(true)
 		if !file.IsDir && body != nil {
-			req = req.Media(body)
+			req = req.Media(body).Pinned(pinned)
 		}
 		if uploaded, err = req.Do(); err != nil {
 			return
@@ -152,9 +474,9 @@ func (r *Remote) Upsert(parentId string, file *File, body io.Reader) (f *File, e
 		return NewRemoteFile(uploaded), nil
 	}
 	// update the existing
-	req := r.service.Files.Update(file.Id, uploaded)
+	req := r.service.Files.Update(file.Id, uploaded).SupportsAllDrives(true)
 	if !file.IsDir && body != nil {
-		req = req.Media(body)
+		req = req.Media(body).Pinned(pinned)
 	}
 	if uploaded, err = req.Do(); err != nil {
 		return
@@ -162,11 +484,40 @@ func (r *Remote) Upsert(parentId string, file *File, body io.Reader) (f *File, e
 	return NewRemoteFile(uploaded), nil
 }
 
+// list returns a call that lists the files matching the query,
+// including the items of shared drives. If the context is bound to a
+// shared drive, the listing is restricted to that drive.
+func (r *Remote) list(q string) *drive.FilesListCall {
+	req := r.service.Files.List().Q(q)
+	req.SupportsAllDrives(true).IncludeItemsFromAllDrives(true)
+	if r.driveId != "" {
+		req.Corpora("drive").DriveId(r.driveId)
+	}
+	return req
+}
+
+// escapeQuery escapes a string to be quoted in a Drive query.
+func escapeQuery(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+// findSharedWithMe finds the folder shared with the user under the
+// title. It searches the user's corpus, since the files shared with the
+// user are never in a shared drive.
+func (r *Remote) findSharedWithMe(title string) (file *File, err error) {
+	q := fmt.Sprintf("sharedWithMe and title = '%s' and trashed=false", escapeQuery(title))
+	req := r.service.Files.List().Q(q).SupportsAllDrives(true).IncludeItemsFromAllDrives(true)
+	files, err := req.Do()
+	if err != nil || len(files.Items) < 1 {
+		return nil, ErrPathNotExists
+	}
+	return NewRemoteFile(files.Items[0]), nil
+}
+
 func (r *Remote) findByPathRecv(parentId string, p []string) (file *File, err error) {
 	// find the file or directory under parentId and titled with p[0]
-	req := r.service.Files.List()
 	// TODO: use field selectors
-	req.Q(fmt.Sprintf("'%s' in parents and title = '%s' and trashed=false", parentId, p[0]))
+	req := r.list(fmt.Sprintf("'%s' in parents and title = '%s' and trashed=false", parentId, escapeQuery(p[0])))
 	files, err := req.Do()
 	if err != nil || len(files.Items) < 1 {
 		// TODO: make sure only 404s are handled here
@@ -179,25 +530,57 @@ func (r *Remote) findByPathRecv(parentId string, p []string) (file *File, err er
 	return r.findByPathRecv(file.Id, p[1:])
 }
 
-func newAuthConfig(context *config.Context) *oauth.Config {
+// isMappedToFolder reports whether the context is mapped to a folder
+// rather than to the root of My Drive or of a shared drive.
+func (r *Remote) isMappedToFolder() bool {
+	return r.rootId != myDriveRootId && r.rootId != r.driveId
+}
+
+// pathOf resolves the path of f by walking up its first parents until
+// the root is reached. Resolved directory paths are cached in dirs.
+func (r *Remote) pathOf(f *drive.File, dirs map[string]string) (p string, err error) {
+	if len(f.Parents) == 0 {
+		return "", ErrPathNotExists
+	}
+	parent := f.Parents[0]
+	dir, ok := dirs[parent.Id]
+	switch {
+	case parent.Id == r.rootId || parent.IsRoot && r.rootId == myDriveRootId:
+		dir, ok = "/", true
+	case parent.IsRoot:
+		// reached My Drive without passing through the context root
+		return "", ErrPathNotExists
+	}
+	if !ok {
+		var pf *drive.File
+		if pf, err = r.service.Files.Get(parent.Id).SupportsAllDrives(true).Do(); err != nil {
+			return
+		}
+		if dir, err = r.pathOf(pf, dirs); err != nil {
+			return
+		}
+		dirs[parent.Id] = dir
+	}
+	return path.Join(dir, f.Title), nil
+}
+
+func newAuthConfig(creds *config.Credentials) *oauth.Config {
 	return &oauth.Config{
-		ClientId:     context.ClientId,
-		ClientSecret: context.ClientSecret,
+		ClientId:     creds.ClientId,
+		ClientSecret: creds.ClientSecret,
 		AuthURL:      GoogleOAuth2AuthURL,
 		TokenURL:     GoogleOAuth2TokenURL,
-		RedirectURL:  RedirectURL,
 		AccessType:   AccessType,
 		Scope:        DriveScope,
 	}
 }
 
-func newTransport(context *config.Context) *oauth.Transport {
-	return &oauth.Transport{
-		Config:    newAuthConfig(context),
-		Transport: http.DefaultTransport,
-		Token: &oauth.Token{
-			RefreshToken: context.RefreshToken,
-			Expiry:       time.Now(),
-		},
+func newTransport(context *config.Context) http.RoundTripper {
+	if context.CredentialsType() == config.CredentialsServiceAccount {
+		return &serviceAccountTransport{
+			keyFile: context.ServiceAccountKey,
+			subject: context.Subject,
+		}
 	}
+	return &userTransport{creds: &context.Credentials}
 }
@@ -0,0 +1,39 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+
+	"github.com/rakyll/drive/config"
+)
+
+func TestIsMappedToFolder(t *testing.T) {
+	tests := []struct {
+		context config.Context
+		want    bool
+	}{
+		{config.Context{}, false},
+		{config.Context{DriveId: "drive"}, false},
+		{config.Context{RootId: "folder"}, true},
+		{config.Context{DriveId: "drive", RootId: "folder"}, true},
+	}
+	for _, tt := range tests {
+		r := NewRemoteContext(&tt.context)
+		if got := r.isMappedToFolder(); got != tt.want {
+			t.Errorf("isMappedToFolder() for %+v = %t; want %t", tt.context, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const reviewHelp = `y - apply this change
+n - do not apply this change
+a - apply this change and all changes under it
+s - skip this change and all changes under it
+d - show the diff of this change
+q - quit; do not apply this or any of the remaining changes
+? - print help`
+
+// byPath sorts changes by path component, so that the changes under a
+// directory come right after the directory itself.
+type byPath []*Change
+
+func (p byPath) Len() int           { return len(p) }
+func (p byPath) Less(i, j int) bool { return pathLess(p[i].Path, p[j].Path) }
+func (p byPath) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// pathLess compares two slash separated paths component by component.
+func pathLess(a, b string) bool {
+	as, bs := strings.Split(strings.Trim(a, "/"), "/"), strings.Split(strings.Trim(b, "/"), "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+	return len(as) < len(bs)
+}
+
+// selectChangeList prints the change list and returns the changes
+// that should be applied. In interactive mode, the user is asked about
+// each change; otherwise, the whole list is confirmed at once. Only the
+// selected deletions are checked against the deletion limits.
+func (g *Commands) selectChangeList(cl []*Change) (selected []*Change, ok bool, err error) {
+	if !g.opts.IsInteractive || len(cl) == 0 {
+		if err = g.checkDeletions(cl); err != nil {
+			return
+		}
+		return cl, printChangeList(cl, g.opts.IsNoPrompt), nil
+	}
+	if selected = g.reviewChangeList(cl); len(selected) == 0 {
+		fmt.Println("No changes selected.")
+		return nil, false, nil
+	}
+	if err = g.checkDeletions(selected); err != nil {
+		return nil, false, err
+	}
+	return selected, true, nil
+}
+
+// reviewChangeList walks through the changes in path order and lets the
+// user accept or reject them one by one or a subtree at a time, similar
+// to git add -p. It returns the accepted changes.
+func (g *Commands) reviewChangeList(cl []*Change) (accepted []*Change) {
+	sort.Sort(byPath(cl))
+	for i := 0; i < len(cl); i++ {
+		c := cl[i]
+		input := ""
+		fmt.Printf("%s %s [y,n,a,s,d,q,?]: ", c.Symbol(), c.Path)
+		if _, err := fmt.Scanln(&input); err == io.EOF {
+			// there is nobody left to answer
+			fmt.Println()
+			return
+		}
+		switch strings.ToLower(input) {
+		case "y":
+			accepted = append(accepted, c)
+		case "n":
+		case "a":
+			end := subtreeEnd(cl, i)
+			accepted = append(accepted, cl[i:end]...)
+			i = end - 1
+		case "s":
+			i = subtreeEnd(cl, i) - 1
+		case "d":
+			if err := g.diffChange(c); err != nil {
+				fmt.Println(err)
+			}
+			i--
+		case "q":
+			return
+		default:
+			fmt.Println(reviewHelp)
+			i--
+		}
+	}
+	return
+}
+
+// subtreeEnd returns the index of the first change after i that is not
+// under the path of cl[i]. cl should be sorted by path.
+func subtreeEnd(cl []*Change, i int) int {
+	prefix := strings.TrimSuffix(cl[i].Path, "/") + "/"
+	j := i + 1
+	for ; j < len(cl); j++ {
+		if !strings.HasPrefix(cl[j].Path, prefix) {
+			break
+		}
+	}
+	return j
+}
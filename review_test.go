@@ -0,0 +1,75 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func changesOf(paths ...string) (cl []*Change) {
+	for _, p := range paths {
+		cl = append(cl, &Change{Path: p})
+	}
+	return
+}
+
+func pathsOf(cl []*Change) (paths []string) {
+	for _, c := range cl {
+		paths = append(paths, c.Path)
+	}
+	return
+}
+
+func TestByPath(t *testing.T) {
+	tests := []struct {
+		in, want []string
+	}{
+		{
+			in:   []string{"/a.txt", "/a/x", "/a-b", "/a"},
+			want: []string{"/a", "/a/x", "/a-b", "/a.txt"},
+		},
+		{
+			in:   []string{"/b/c/d", "/b", "/b/c", "/a/z", "/b/c.d"},
+			want: []string{"/a/z", "/b", "/b/c", "/b/c/d", "/b/c.d"},
+		},
+	}
+	for _, tt := range tests {
+		cl := changesOf(tt.in...)
+		sort.Sort(byPath(cl))
+		if got := pathsOf(cl); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sorted %v = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSubtreeEnd(t *testing.T) {
+	cl := changesOf("/a", "/a/x", "/a/y/z", "/a-b", "/a.txt", "/b")
+	sort.Sort(byPath(cl))
+	tests := []struct {
+		i, want int
+	}{
+		{0, 3}, // /a covers /a/x and /a/y/z, but not /a-b
+		{1, 2},
+		{3, 4},
+		{5, 6},
+	}
+	for _, tt := range tests {
+		if got := subtreeEnd(cl, tt.i); got != tt.want {
+			t.Errorf("subtreeEnd(%s) = %d, want %d", cl[tt.i].Path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+var ErrNoRevisions = errors.New("folders have no revisions")
+
+// Revisions prints the revisions of the remote file at Path, oldest
+// first. Revisions kept forever are marked with a *.
+func (g *Commands) Revisions() (err error) {
+	var f *File
+	if f, err = g.remoteFile(); err != nil {
+		return
+	}
+	var revs []*Revision
+	if revs, err = g.rem.FindRevisions(f.Id); err != nil {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	defer w.Flush()
+	for _, r := range revs {
+		pinned, author, sum := " ", "-", r.Md5Checksum
+		if r.Pinned {
+			pinned = "*"
+		}
+		if r.Author != nil {
+			author = r.Author.String()
+		}
+		if sum == "" {
+			sum = "-"
+		}
+		head := ""
+		if r.Id == f.HeadRevisionId {
+			head = " (head)"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\t%d\t%s%s\n",
+			pinned, r.Id, r.ModTime.Local().Format("2006-01-02 15:04"), author, r.Size, sum, head)
+	}
+	return
+}
+
+// PullRevision replaces the local copy of the file at Path with the
+// given revision. The replaced copy is kept in the local trash; pushing
+// afterwards makes the revision the head revision again.
+func (g *Commands) PullRevision(revisionId string) (err error) {
+	var f *File
+	if f, err = g.remoteFile(); err != nil {
+		return
+	}
+	var rev *Revision
+	if rev, err = g.rem.FindRevision(f.Id, revisionId); err != nil {
+		return
+	}
+	var blob io.ReadCloser
+	if blob, err = g.rem.DownloadRevision(f.Id, revisionId); err != nil {
+		return
+	}
+	defer blob.Close()
+
+	absPath := g.context.AbsPathOf(g.opts.Path)
+	if err = os.MkdirAll(filepath.Dir(absPath), os.ModeDir|0755); err != nil {
+		return
+	}
+	// without a local copy there is nothing to trash, and undoing the
+	// pull only removes the downloaded revision
+	op := OpAdd
+	if _, e := os.Lstat(absPath); e == nil {
+		op = OpMod
+		if err = g.moveToLocalTrash(g.opts.Path); err != nil {
+			return
+		}
+	}
+	var fo *os.File
+	if fo, err = os.Create(absPath); err != nil {
+		return
+	}
+	if _, err = io.Copy(fo, blob); err != nil {
+		fo.Close()
+		return
+	}
+	if err = fo.Close(); err != nil {
+		return
+	}
+	g.record(cmdPull, op, g.opts.Path, f.Id, nil)
+	fmt.Printf("Pulled revision %s of %s\n", revisionId, g.opts.Path)
+	return os.Chtimes(absPath, rev.ModTime, rev.ModTime)
+}
+
+// Revert makes the given revision the head revision of the remote file
+// at Path. It can be undone with gd undo.
+func (g *Commands) Revert(revisionId string) (err error) {
+	var f *File
+	if f, err = g.remoteFile(); err != nil {
+		return
+	}
+	if err = g.rem.Revert(f.Id, revisionId); err != nil {
+		return
+	}
+	g.record(cmdRevert, OpMod, g.opts.Path, f.Id, f)
+	fmt.Printf("Reverted %s to revision %s; pull to update the local copy\n", g.opts.Path, revisionId)
+	return
+}
+
+// remoteFile finds the remote file at Path, which can't be a folder.
+func (g *Commands) remoteFile() (f *File, err error) {
+	if f, err = g.rem.FindByPath(g.opts.Path); err != nil {
+		return
+	}
+	if f.IsDir {
+		return nil, ErrNoRevisions
+	}
+	return
+}
@@ -0,0 +1,100 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+)
+
+var (
+	ErrIsDir            = errors.New("path is a folder; use -r to remove it")
+	ErrCannotRemoveRoot = errors.New("cannot remove the context root")
+)
+
+// Remove trashes the remote file at Path, or deletes it permanently if
+// isPermanent is set, and moves the local copy into the local trash so
+// that the next push doesn't upload it again. Folders are only removed
+// if IsRecursive is set. The deletions are checked against the same
+// limits as push and confirmed unless IsNoPrompt is set.
+func (g *Commands) Remove(isPermanent bool) (err error) {
+	if g.opts.Path == "/" {
+		return ErrCannotRemoveRoot
+	}
+	var f *File
+	if f, err = g.rem.FindByPath(g.opts.Path); err != nil {
+		return
+	}
+	if f.IsDir && !g.opts.IsRecursive {
+		return ErrIsDir
+	}
+	var cl []*Change
+	if cl, err = g.deletionsRecv(g.opts.Path, f); err != nil {
+		return
+	}
+	if err = g.checkDeletions(cl); err != nil {
+		return
+	}
+	for _, c := range cl {
+		fmt.Println(c.Symbol(), c.Path)
+	}
+	question := fmt.Sprintf("Move %d file(s) to the trash?", len(cl))
+	if isPermanent {
+		question = fmt.Sprintf("Permanently delete %d file(s)? This can't be undone.", len(cl))
+	}
+	if !g.confirm(question) {
+		return
+	}
+
+	// trashing or deleting the top file takes its children along
+	if isPermanent {
+		err = g.rem.Delete(f.Id)
+	} else {
+		err = g.rem.Trash(f.Id)
+	}
+	if err != nil {
+		return
+	}
+	if !isPermanent {
+		g.record(cmdRm, OpDelete, g.opts.Path, f.Id, f)
+	}
+	if _, e := os.Lstat(g.context.AbsPathOf(g.opts.Path)); e == nil {
+		return g.moveToLocalTrash(g.opts.Path)
+	}
+	return
+}
+
+// deletionsRecv lists the deletions of the file and, if it is a folder,
+// of its descendants.
+func (g *Commands) deletionsRecv(p string, f *File) (cl []*Change, err error) {
+	cl = append(cl, &Change{Path: p, Dest: f})
+	if !f.IsDir {
+		return
+	}
+	var children []*File
+	if children, err = g.rem.FindByParentId(f.Id); err != nil {
+		return
+	}
+	for _, c := range children {
+		var childChanges []*Change
+		if childChanges, err = g.deletionsRecv(path.Join(p, c.Name), c); err != nil {
+			return
+		}
+		cl = append(cl, childChanges...)
+	}
+	return
+}
@@ -0,0 +1,60 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// checkDeletions aborts the run if the change list deletes more than
+// the configured limits allow or touches the context root's direct
+// children without AllowRootDeletes. The offending deletions are
+// printed before the error is returned.
+func (g *Commands) checkDeletions(cl []*Change) error {
+	var deletes, rootDeletes []*Change
+	for _, c := range cl {
+		if c.Op() != OpDelete {
+			continue
+		}
+		deletes = append(deletes, c)
+		if path.Dir(c.Path) == "/" || c.Path == "/" {
+			rootDeletes = append(rootDeletes, c)
+		}
+	}
+
+	var err error
+	switch {
+	case len(rootDeletes) > 0 && !g.opts.AllowRootDeletes:
+		deletes = rootDeletes
+		err = fmt.Errorf("aborted: %d deletion(s) at the context root; use -allow-root-deletes to apply them", len(rootDeletes))
+	case g.opts.MaxDeletes > 0 && len(deletes) > g.opts.MaxDeletes:
+		err = fmt.Errorf("aborted: %d deletions exceed the limit of %d; use -max-deletes to raise it", len(deletes), g.opts.MaxDeletes)
+	case g.opts.MaxDeletePercent > 0 && g.numOfDestFiles > 0 &&
+		len(deletes)*100 > g.opts.MaxDeletePercent*int(g.numOfDestFiles):
+		err = fmt.Errorf("aborted: %d of %d files would be deleted, more than %d%%; use -max-delete-percent to raise it",
+			len(deletes), g.numOfDestFiles, g.opts.MaxDeletePercent)
+	}
+	if err == nil {
+		return nil
+	}
+	sort.Sort(byPath(deletes))
+	fmt.Println("The following would have been deleted:")
+	for _, c := range deletes {
+		fmt.Println(c.Symbol(), c.Path)
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"testing"
+)
+
+func deletionsOf(paths ...string) (cl []*Change) {
+	for _, p := range paths {
+		cl = append(cl, &Change{Path: p, Dest: &File{Name: p}})
+	}
+	return
+}
+
+func manyDeletions(n int) []string {
+	var paths []string
+	for i := 0; i < n; i++ {
+		paths = append(paths, fmt.Sprintf("/dir/%d", i))
+	}
+	return paths
+}
+
+func TestCheckDeletions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		dest    int64
+		cl      []*Change
+		wantErr bool
+	}{
+		{"no deletions", Options{MaxDeletes: 1}, 10, []*Change{{Path: "/a", Src: &File{}}}, false},
+		{"root child", Options{}, 10, deletionsOf("/a"), true},
+		{"root child allowed", Options{AllowRootDeletes: true}, 10, deletionsOf("/a"), false},
+		{"nested", Options{}, 10, deletionsOf("/dir/a"), false},
+		{"at the limit", Options{MaxDeletes: 3}, 100, deletionsOf(manyDeletions(3)...), false},
+		{"over the limit", Options{MaxDeletes: 3}, 100, deletionsOf(manyDeletions(4)...), true},
+		{"no limit", Options{}, 100, deletionsOf(manyDeletions(50)...), false},
+		{"at the percentage", Options{MaxDeletePercent: 10}, 40, deletionsOf(manyDeletions(4)...), false},
+		{"over the percentage", Options{MaxDeletePercent: 10}, 40, deletionsOf(manyDeletions(5)...), true},
+		{"unknown destination", Options{MaxDeletePercent: 10}, 0, deletionsOf(manyDeletions(5)...), false},
+	}
+	for _, tt := range tests {
+		opts := tt.opts
+		g := &Commands{opts: &opts, numOfDestFiles: tt.dest}
+		if err := g.checkDeletions(tt.cl); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkDeletions() = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSelectChangeListChecksSelectedDeletions(t *testing.T) {
+	g := &Commands{opts: &Options{IsNoPrompt: true, MaxDeletes: 1}}
+	if _, ok, err := g.selectChangeList(deletionsOf("/dir/a", "/dir/b")); err == nil || ok {
+		t.Errorf("selectChangeList() = %t, %v; want the deletions to be refused", ok, err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rakyll/drive/config"
+)
+
+// ConfigList prints the effective settings and where they come from.
+func (g *Commands) ConfigList() (err error) {
+	var s *config.Settings
+	if s, err = config.LoadSettings(g.context); err != nil {
+		return
+	}
+	for _, key := range s.Keys() {
+		fmt.Printf("%s = %s (%s)\n", key, s.Get(key), s.Origin(key))
+	}
+	return
+}
+
+// ConfigGet prints the effective value of a setting.
+func (g *Commands) ConfigGet(key string) (err error) {
+	if !config.IsSetting(key) {
+		return fmt.Errorf("unknown setting: %s", key)
+	}
+	var s *config.Settings
+	if s, err = config.LoadSettings(g.context); err != nil {
+		return
+	}
+	fmt.Println(s.Get(key))
+	return
+}
+
+// ConfigSet sets a setting in the context's config file, or in the
+// user's if isUser is set. An empty value removes the setting.
+func (g *Commands) ConfigSet(key, value string, isUser bool) error {
+	if isUser {
+		return config.WriteSetting(config.UserSettingsPath(), key, value)
+	}
+	if g.context == nil {
+		return errors.New("no gd context is found; use -user to change the user's settings")
+	}
+	return config.WriteSetting(g.context.SettingsPath(), key, value)
+}
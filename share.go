@@ -0,0 +1,192 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Types of the grantees of permissions.
+const (
+	GranteeUser   = "user"
+	GranteeGroup  = "group"
+	GranteeDomain = "domain"
+	GranteeAnyone = "anyone"
+)
+
+var (
+	ErrUnknownRole       = errors.New("unknown role; use reader, commenter, writer or owner")
+	ErrCannotExpire      = errors.New("only the access of users and groups can expire")
+	ErrPermissionMissing = errors.New("the grantee has no permission on the file")
+)
+
+// Grant describes the access to give to a grantee.
+type Grant struct {
+	// Grantee is an email, a domain or anyone
+	Grantee string
+	// Role is one of reader, commenter, writer or owner
+	Role string
+	// IsGroup is set if the email is a group's
+	IsGroup bool
+	// Notify sends a notification email to the users and groups
+	Notify  bool
+	Message string
+	// Expiration is when the access of a user or a group expires, nil
+	// if it doesn't
+	Expiration *time.Time
+}
+
+func (gr *Grant) permission() (p *Permission, err error) {
+	switch gr.Role {
+	case "reader", "commenter", "writer", "owner":
+	default:
+		return nil, ErrUnknownRole
+	}
+	p = &Permission{Role: gr.Role}
+	switch {
+	case gr.Grantee == GranteeAnyone:
+		p.Type = GranteeAnyone
+	case strings.Contains(gr.Grantee, "@") && gr.IsGroup:
+		p.Type, p.Email = GranteeGroup, gr.Grantee
+	case strings.Contains(gr.Grantee, "@"):
+		p.Type, p.Email = GranteeUser, gr.Grantee
+	default:
+		p.Type, p.Domain = GranteeDomain, gr.Grantee
+	}
+	if gr.Expiration != nil {
+		if p.Type != GranteeUser && p.Type != GranteeGroup {
+			return nil, ErrCannotExpire
+		}
+		p.Expiration = gr.Expiration
+	}
+	return
+}
+
+// matches reports whether the permission is granted to the grantee.
+func (p *Permission) matches(grantee string) bool {
+	switch p.Type {
+	case GranteeAnyone:
+		return grantee == GranteeAnyone
+	case GranteeDomain:
+		return strings.EqualFold(p.Domain, grantee)
+	}
+	return strings.EqualFold(p.Email, grantee)
+}
+
+// Share grants access to the file at Path, and to its descendants if
+// IsRecursive is set. The grantee is only notified about the file at
+// Path, not once for every descendant.
+func (g *Commands) Share(gr *Grant) (err error) {
+	var perm *Permission
+	if perm, err = gr.permission(); err != nil {
+		return
+	}
+	return g.walkRemote(func(p string, f *File) error {
+		notify := gr.Notify && p == g.opts.Path
+		if err := g.rem.Share(f.Id, perm, notify, gr.Message); err != nil {
+			return err
+		}
+		fmt.Printf("Shared %s with %s (%s)\n", p, gr.Grantee, gr.Role)
+		return nil
+	})
+}
+
+// Unshare removes the permission of the grantee from the file at Path,
+// and from its descendants if IsRecursive is set. Descendants the
+// grantee has no permission of its own on are skipped.
+func (g *Commands) Unshare(grantee string) (err error) {
+	return g.walkRemote(func(p string, f *File) error {
+		perms, err := g.rem.FindPermissions(f.Id)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, perm := range perms {
+			if !perm.matches(grantee) {
+				continue
+			}
+			found = true
+			if err = g.rem.Unshare(f.Id, perm.Id); err != nil {
+				return err
+			}
+		}
+		if !found {
+			if p == g.opts.Path {
+				return ErrPermissionMissing
+			}
+			return nil
+		}
+		fmt.Printf("Unshared %s with %s\n", p, grantee)
+		return nil
+	})
+}
+
+// ListPermissions prints who can access the file at Path.
+func (g *Commands) ListPermissions() (err error) {
+	var f *File
+	if f, err = g.rem.FindByPath(g.opts.Path); err != nil {
+		return
+	}
+	var perms []*Permission
+	if perms, err = g.rem.FindPermissions(f.Id); err != nil {
+		return
+	}
+	for _, p := range perms {
+		fmt.Println(formatPermission(p))
+	}
+	return
+}
+
+// walkRemote calls fn for the remote file at Path and, if IsRecursive
+// is set, for each of its descendants. Failures on descendants are
+// printed and don't stop the walk.
+func (g *Commands) walkRemote(fn func(p string, f *File) error) (err error) {
+	var f *File
+	if f, err = g.rem.FindByPath(g.opts.Path); err != nil {
+		return
+	}
+	if err = fn(g.opts.Path, f); err != nil || !f.IsDir || !g.opts.IsRecursive {
+		return
+	}
+	failed := 0
+	var walk func(p string, f *File)
+	walk = func(p string, f *File) {
+		children, err := g.rem.FindByParentId(f.Id)
+		if err != nil {
+			fmt.Printf("%s: %v\n", p, err)
+			failed++
+			return
+		}
+		for _, c := range children {
+			childPath := path.Join(p, c.Name)
+			if err := fn(childPath, c); err != nil {
+				fmt.Printf("%s: %v\n", childPath, err)
+				failed++
+			}
+			if c.IsDir {
+				walk(childPath, c)
+			}
+		}
+	}
+	walk(g.opts.Path, f)
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) failed", failed)
+	}
+	return
+}
@@ -0,0 +1,49 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGrantPermission(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	tests := []struct {
+		grant    Grant
+		wantType string
+		err      error
+	}{
+		{Grant{Grantee: "me@example.com", Role: "reader"}, GranteeUser, nil},
+		{Grant{Grantee: "team@example.com", Role: "writer", IsGroup: true}, GranteeGroup, nil},
+		{Grant{Grantee: "example.com", Role: "commenter"}, GranteeDomain, nil},
+		{Grant{Grantee: GranteeAnyone, Role: "reader"}, GranteeAnyone, nil},
+		{Grant{Grantee: "me@example.com", Role: "editor"}, "", ErrUnknownRole},
+		{Grant{Grantee: "me@example.com", Role: "reader", Expiration: &later}, GranteeUser, nil},
+		{Grant{Grantee: "team@example.com", Role: "reader", IsGroup: true, Expiration: &later}, GranteeGroup, nil},
+		{Grant{Grantee: "example.com", Role: "reader", Expiration: &later}, "", ErrCannotExpire},
+		{Grant{Grantee: GranteeAnyone, Role: "reader", Expiration: &later}, "", ErrCannotExpire},
+	}
+	for _, tt := range tests {
+		p, err := tt.grant.permission()
+		if err != tt.err {
+			t.Errorf("%+v.permission() = %v; want %v", tt.grant, err, tt.err)
+			continue
+		}
+		if err == nil && (p.Type != tt.wantType || p.Expiration != tt.grant.Expiration) {
+			t.Errorf("%+v.permission() = %+v; want type %s", tt.grant, p, tt.wantType)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SparseList prints the patterns of the subtrees that are checked out.
+func (g *Commands) SparseList() (err error) {
+	var patterns []string
+	if patterns, err = g.context.ReadSparse(); err != nil {
+		return
+	}
+	if len(patterns) == 0 {
+		fmt.Println("Sparse checkout is disabled; everything is checked out.")
+		return
+	}
+	for _, pattern := range patterns {
+		fmt.Println(pattern)
+	}
+	return
+}
+
+// SparseAdd includes the subtrees matching the pattern in Path in the
+// checkout and pulls them.
+func (g *Commands) SparseAdd() (err error) {
+	pattern := g.opts.Path
+	var patterns []string
+	if patterns, err = g.context.ReadSparse(); err != nil {
+		return
+	}
+	for _, p := range patterns {
+		if p == pattern {
+			return fmt.Errorf("%s is already checked out", pattern)
+		}
+	}
+	if err = g.context.WriteSparse(append(patterns, pattern)); err != nil {
+		return
+	}
+	g.opts.Path = sparseBase(pattern)
+	return g.Pull()
+}
+
+// SparseRemove excludes the subtrees matching the pattern in Path from
+// the checkout and moves the local files that are no longer checked out
+// into the local trash.
+func (g *Commands) SparseRemove() (err error) {
+	pattern := g.opts.Path
+	var patterns, kept []string
+	if patterns, err = g.context.ReadSparse(); err != nil {
+		return
+	}
+	for _, p := range patterns {
+		if p != pattern {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(patterns) {
+		return fmt.Errorf("%s is not checked out", pattern)
+	}
+	if err = g.context.WriteSparse(kept); err != nil {
+		return
+	}
+	if len(kept) == 0 {
+		// nothing to prune, the whole context is checked out again
+		return
+	}
+	g.sparse = kept
+
+	base := g.context.AbsPathOf(sparseBase(pattern))
+	var pruned []string
+	err = filepath.Walk(base, func(abs string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(g.context.AbsPath, abs)
+		p := path.Join("/", filepath.ToSlash(rel))
+		if p == "/.gd" {
+			return filepath.SkipDir
+		}
+		if g.isSparseIncluded(p) {
+			return nil
+		}
+		pruned = append(pruned, p)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	for _, p := range pruned {
+		if err = g.moveToLocalTrash(p); err != nil {
+			return
+		}
+		fmt.Println("Pruned", p)
+	}
+	return
+}
+
+// isSparseIncluded reports whether the path is checked out. A path is
+// checked out if it matches one of the sparse patterns, is under a
+// matching path or is on the way to one. If there are no patterns,
+// everything is checked out.
+func (g *Commands) isSparseIncluded(p string) bool {
+	if len(g.sparse) == 0 || p == "/" {
+		return true
+	}
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	for _, pattern := range g.sparse {
+		patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+		matched := true
+		for i := 0; i < len(parts) && i < len(patternParts); i++ {
+			if ok, _ := path.Match(patternParts[i], parts[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// sparseBase returns the longest leading path of the pattern that has
+// no wildcards.
+func sparseBase(pattern string) string {
+	base := "/"
+	for _, part := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if strings.ContainsAny(part, "*?[\\") {
+			break
+		}
+		base = path.Join(base, part)
+	}
+	return base
+}
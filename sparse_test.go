@@ -0,0 +1,56 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import "testing"
+
+func TestIsSparseIncluded(t *testing.T) {
+	tests := []struct {
+		sparse []string
+		path   string
+		want   bool
+	}{
+		{nil, "/anything", true},
+		{[]string{"/docs"}, "/", true},
+		{[]string{"/docs"}, "/docs", true},
+		{[]string{"/docs"}, "/docs/a/b", true},
+		{[]string{"/docs/2014"}, "/docs", true},
+		{[]string{"/docs/2014"}, "/docs/2013", false},
+		{[]string{"/docs"}, "/docsx", false},
+		{[]string{"/photos/*/raw"}, "/photos/trip/raw/1.cr2", true},
+		{[]string{"/photos/*/raw"}, "/photos/trip/jpg", false},
+		{[]string{"/docs", "/music"}, "/music/a.mp3", true},
+	}
+	for _, tt := range tests {
+		g := &Commands{sparse: tt.sparse}
+		if got := g.isSparseIncluded(tt.path); got != tt.want {
+			t.Errorf("with %v, isSparseIncluded(%s) = %t; want %t", tt.sparse, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSparseBase(t *testing.T) {
+	tests := []struct{ pattern, want string }{
+		{"/docs", "/docs"},
+		{"/docs/2014/*.pdf", "/docs/2014"},
+		{"/photos/*/raw", "/photos"},
+		{"/*", "/"},
+	}
+	for _, tt := range tests {
+		if got := sparseBase(tt.pattern); got != tt.want {
+			t.Errorf("sparseBase(%s) = %s; want %s", tt.pattern, got, tt.want)
+		}
+	}
+}
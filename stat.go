@@ -0,0 +1,128 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// FileStat is the remote metadata of a file and who can access it.
+type FileStat struct {
+	Path string `json:"path"`
+	*File
+	Permissions []*Permission `json:"permissions"`
+}
+
+// Stat prints the remote metadata and the permissions of the file at
+// the path, as JSON if isJSON is set.
+func (g *Commands) Stat(isJSON bool) (err error) {
+	var f *File
+	if f, err = g.rem.FindByPath(g.opts.Path); err != nil {
+		return
+	}
+	stat := &FileStat{Path: g.opts.Path, File: f}
+	if stat.Permissions, err = g.rem.FindPermissions(f.Id); err != nil {
+		return
+	}
+	if isJSON {
+		var data []byte
+		if data, err = json.MarshalIndent(stat, "", "  "); err != nil {
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+	printStat(stat)
+	return
+}
+
+func printStat(s *FileStat) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	defer w.Flush()
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", name, value)
+		}
+	}
+	kind := "file"
+	if s.IsDir {
+		kind = "folder"
+	}
+	var owners []string
+	for _, o := range s.Owners {
+		owners = append(owners, o.String())
+	}
+	field("Path", s.Path)
+	field("Name", s.Name)
+	field("Id", s.Id)
+	field("Type", kind)
+	field("MIME type", s.MimeType)
+	if !s.IsDir {
+		field("Size", fmt.Sprintf("%d", s.Size))
+		field("MD5", s.Md5Checksum)
+		field("Revision", s.HeadRevisionId)
+	}
+	field("Version", fmt.Sprintf("%d", s.Version))
+	if s.CreatedTime != nil {
+		field("Created", formatTime(*s.CreatedTime))
+	}
+	field("Modified", formatTime(s.ModTime))
+	if s.LastModifyingUser != nil {
+		field("Modified by", s.LastModifyingUser.String())
+	}
+	field("Owners", strings.Join(owners, ", "))
+	field("Parents", strings.Join(s.Parents, ", "))
+	field("Drive", s.DriveId)
+	field("Shared", fmt.Sprintf("%t", s.Shared))
+	field("Starred", fmt.Sprintf("%t", s.Starred))
+	field("Downloadable", fmt.Sprintf("%t", !s.Restricted))
+	field("Description", s.Description)
+	field("View link", s.WebViewLink)
+	field("Download link", s.WebContentLink)
+	for _, p := range s.Permissions {
+		field("Permission", formatPermission(p))
+	}
+}
+
+func formatPermission(p *Permission) string {
+	who := p.Type
+	switch {
+	case p.Email != "" && p.Name != "":
+		who = fmt.Sprintf("%s <%s>", p.Name, p.Email)
+	case p.Email != "":
+		who = p.Email
+	case p.Domain != "":
+		who = p.Domain
+	}
+	if p.WithLink {
+		who += " with the link"
+	}
+	if p.Expiration != nil {
+		return fmt.Sprintf("%s (%s until %s)", who, p.Role, formatTime(*p.Expiration))
+	}
+	return fmt.Sprintf("%s (%s)", who, p.Role)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(time.RFC1123)
+}
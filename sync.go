@@ -0,0 +1,296 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// indexEntry is the state of a path as of the last time its local and
+// remote copies were in sync.
+type indexEntry struct {
+	Id    string `json:"id"`
+	IsDir bool   `json:"dir,omitempty"`
+	Size  int64  `json:"size,omitempty"`
+	Md5   string `json:"md5,omitempty"`
+	// ModTime is the modification time of the local copy, RemoteModTime
+	// of the remote one.
+	ModTime       time.Time `json:"mtime"`
+	RemoteModTime time.Time `json:"remote_mtime"`
+}
+
+// syncIndex maps the context relative paths to their last synced state.
+type syncIndex map[string]*indexEntry
+
+// Sync pushes the local changes and pulls the remote changes made since
+// the last sync, telling them apart with the context's sync index. A
+// path that has changed on both sides is a conflict; it is left alone
+// and reported in the returned error. The push and the pull are
+// journaled as separate runs.
+func (g *Commands) Sync() (err error) {
+	if g.sparse, err = g.context.ReadSparse(); err != nil {
+		return
+	}
+	var index syncIndex
+	if index, err = g.readIndex(); err != nil {
+		return
+	}
+	var pairs []*Change
+	if pairs, err = g.syncPairs(); err != nil {
+		return
+	}
+	push, pull, conflicts := reconcile(pairs, index)
+
+	// push first, so that the local changes are safe on the remote
+	// before anything is pulled
+	applied := false
+	for _, step := range []struct {
+		cl   []*Change
+		dest func(pair *Change) *File
+		play func(cl []*Change) error
+	}{
+		{push, func(pair *Change) *File { return pair.Dest }, g.playPushChangeList},
+		{pull, func(pair *Change) *File { return pair.Src }, g.playPullChangeList},
+	} {
+		if len(step.cl) == 0 {
+			continue
+		}
+		g.numOfDestFiles = 0
+		for _, pair := range pairs {
+			if step.dest(pair) != nil {
+				g.numOfDestFiles++
+			}
+		}
+		if err = g.checkDeletions(step.cl); err != nil {
+			return
+		}
+		for _, c := range step.cl {
+			fmt.Println(c.Symbol(), c.Path)
+		}
+		g.newRun()
+		if err = step.play(step.cl); err != nil {
+			return
+		}
+		applied = true
+	}
+
+	if applied {
+		// index what has actually been applied
+		if pairs, err = g.syncPairs(); err != nil {
+			return
+		}
+	}
+	if err = g.writeIndex(updateIndex(index, pairs)); err != nil {
+		return
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%d conflict(s), changed both locally and remotely since the last sync: %s",
+			len(conflicts), strings.Join(conflicts, ", "))
+	}
+	return
+}
+
+// syncPairs walks the local and remote trees under Path and returns
+// every pair of copies, in sync or not, as a push would see them.
+func (g *Commands) syncPairs() (pairs []*Change, err error) {
+	var r, l *File
+	if r, err = g.rem.FindByPath(g.opts.Path); err != nil && err != ErrPathNotExists {
+		return
+	}
+	absPath := g.context.AbsPathOf(g.opts.Path)
+	if info, e := os.Stat(absPath); e == nil {
+		l = NewLocalFile(absPath, info)
+	}
+	err = g.syncPairsRecv(g.opts.Path, r, l, &pairs)
+	return
+}
+
+func (g *Commands) syncPairsRecv(p string, r, l *File, pairs *[]*Change) (err error) {
+	*pairs = append(*pairs, &Change{Path: p, Src: l, Dest: r})
+	var localChildren, remoteChildren []*File
+	if l != nil && l.IsDir {
+		if localChildren, err = list(g.context, p, g.opts.Hidden); err != nil {
+			return
+		}
+	}
+	if r != nil && r.IsDir {
+		if remoteChildren, err = g.rem.FindByParentId(r.Id); err != nil {
+			return
+		}
+	}
+	for _, child := range merge(remoteChildren, localChildren) {
+		childPath := path.Join(p, child.Name())
+		if g.isIgnored(childPath) || !g.isSparseIncluded(childPath) {
+			continue
+		}
+		if err = g.syncPairsRecv(childPath, child.remote, child.local, pairs); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// reconcile decides for each out of sync pair whether to push or pull
+// it, depending on which side has changed since the entry in the index.
+// Deletions of directories that still hold changes from the other side
+// or conflicts are dropped, so that nothing unsynced is deleted.
+func reconcile(pairs []*Change, index syncIndex) (push, pull []*Change, conflicts []string) {
+	var pushDeletes, pullDeletes []*Change
+	for _, c := range pairs {
+		l, r := c.Src, c.Dest
+		if c.Op() == OpNone || (l != nil && r != nil && !l.IsDir && !r.IsDir && md5Checksum(l) == md5Checksum(r)) {
+			continue
+		}
+		e := index[c.Path]
+		localChanged, remoteChanged := !sameLocal(l, e), !sameRemote(r, e)
+		switch {
+		case localChanged && !remoteChanged:
+			if r != nil && l == nil {
+				pushDeletes = append(pushDeletes, c)
+			} else {
+				push = append(push, c)
+			}
+		case remoteChanged && !localChanged:
+			pc := &Change{Path: c.Path, Src: r, Dest: l}
+			if l != nil && r == nil {
+				pullDeletes = append(pullDeletes, pc)
+			} else {
+				pull = append(pull, pc)
+			}
+		default:
+			conflicts = append(conflicts, c.Path)
+		}
+	}
+	keep := func(d *Change, others []*Change) bool {
+		for _, c := range others {
+			if strings.HasPrefix(c.Path, d.Path+"/") {
+				return false
+			}
+		}
+		for _, p := range conflicts {
+			if strings.HasPrefix(p, d.Path+"/") {
+				return false
+			}
+		}
+		return true
+	}
+	for _, d := range pushDeletes {
+		if keep(d, pull) {
+			push = append(push, d)
+		}
+	}
+	for _, d := range pullDeletes {
+		if keep(d, push) {
+			pull = append(pull, d)
+		}
+	}
+	return
+}
+
+// sameLocal reports whether the local copy is as it was indexed.
+func sameLocal(l *File, e *indexEntry) bool {
+	if l == nil || e == nil {
+		return l == nil && e == nil
+	}
+	if l.IsDir || e.IsDir {
+		return l.IsDir == e.IsDir
+	}
+	return l.Size == e.Size && l.ModTime.Equal(e.ModTime)
+}
+
+// sameRemote reports whether the remote copy is as it was indexed.
+// Files without a checksum, such as Google Docs, are compared by their
+// modification times.
+func sameRemote(r *File, e *indexEntry) bool {
+	if r == nil || e == nil {
+		return r == nil && e == nil
+	}
+	if r.Id != e.Id || r.IsDir != e.IsDir {
+		return false
+	}
+	if r.IsDir {
+		return true
+	}
+	if r.Md5Checksum != "" {
+		return r.Md5Checksum == e.Md5
+	}
+	return r.ModTime.Equal(e.RemoteModTime)
+}
+
+// updateIndex returns the index of the pairs that are in sync. Pairs
+// that are not in sync keep their previous entries, so that the next
+// sync still knows which side has changed.
+func updateIndex(index syncIndex, pairs []*Change) syncIndex {
+	updated := make(syncIndex)
+	for _, c := range pairs {
+		l, r := c.Src, c.Dest
+		if l == nil || r == nil {
+			if e, ok := index[c.Path]; ok {
+				updated[c.Path] = e
+			}
+			continue
+		}
+		md5 := r.Md5Checksum
+		if c.Op() != OpNone && (l.IsDir || r.IsDir || md5Checksum(l) != md5) {
+			if e, ok := index[c.Path]; ok {
+				updated[c.Path] = e
+			}
+			continue
+		}
+		updated[c.Path] = &indexEntry{
+			Id:            r.Id,
+			IsDir:         r.IsDir,
+			Size:          l.Size,
+			Md5:           md5,
+			ModTime:       l.ModTime,
+			RemoteModTime: r.ModTime,
+		}
+	}
+	return updated
+}
+
+func (g *Commands) readIndex() (index syncIndex, err error) {
+	index = make(syncIndex)
+	var data []byte
+	if data, err = ioutil.ReadFile(g.context.IndexPath()); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	err = json.Unmarshal(data, &index)
+	return
+}
+
+func (g *Commands) writeIndex(index syncIndex) (err error) {
+	var data []byte
+	if data, err = json.Marshal(index); err != nil {
+		return
+	}
+	if err = os.MkdirAll(path.Dir(g.context.IndexPath()), 0755); err != nil {
+		return
+	}
+	tmp := g.context.IndexPath() + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	return os.Rename(tmp, g.context.IndexPath())
+}
@@ -0,0 +1,113 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	syncedAt = time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	editedAt = syncedAt.Add(time.Hour)
+)
+
+func localFile(size int64, mtime time.Time, md5 string) *File {
+	return &File{Size: size, ModTime: mtime, Md5Checksum: md5}
+}
+
+func remoteFile(id string, size int64, mtime time.Time, md5 string) *File {
+	return &File{Id: id, Size: size, ModTime: mtime, Md5Checksum: md5}
+}
+
+func TestReconcile(t *testing.T) {
+	synced := &indexEntry{Id: "1", Size: 1, Md5: "a", ModTime: syncedAt, RemoteModTime: syncedAt}
+	tests := []struct {
+		name      string
+		pair      *Change
+		entry     *indexEntry
+		push      bool
+		pull      bool
+		conflicts bool
+	}{
+		{"in sync", &Change{Src: localFile(1, syncedAt, "a"), Dest: remoteFile("1", 1, syncedAt, "a")}, synced, false, false, false},
+		{"local edit", &Change{Src: localFile(2, editedAt, "b"), Dest: remoteFile("1", 1, syncedAt, "a")}, synced, true, false, false},
+		{"remote edit", &Change{Src: localFile(1, syncedAt, "a"), Dest: remoteFile("1", 2, editedAt, "b")}, synced, false, true, false},
+		{"both edited", &Change{Src: localFile(2, editedAt, "b"), Dest: remoteFile("1", 3, editedAt, "c")}, synced, false, false, true},
+		{"same edit on both", &Change{Src: localFile(2, editedAt, "b"), Dest: remoteFile("1", 2, syncedAt, "b")}, synced, false, false, false},
+		{"local add", &Change{Src: localFile(1, editedAt, "a")}, nil, true, false, false},
+		{"remote add", &Change{Dest: remoteFile("1", 1, editedAt, "a")}, nil, false, true, false},
+		{"added on both", &Change{Src: localFile(2, editedAt, "b"), Dest: remoteFile("1", 1, editedAt, "a")}, nil, false, false, true},
+		{"local delete", &Change{Dest: remoteFile("1", 1, syncedAt, "a")}, synced, true, false, false},
+		{"remote delete", &Change{Src: localFile(1, syncedAt, "a")}, synced, false, true, false},
+		{"local delete, remote edit", &Change{Dest: remoteFile("1", 2, editedAt, "b")}, synced, false, false, true},
+		{"remote replaced", &Change{Src: localFile(1, syncedAt, "a"), Dest: remoteFile("2", 2, editedAt, "b")}, synced, false, true, false},
+	}
+	for _, tt := range tests {
+		tt.pair.Path = "/dir/f"
+		index := syncIndex{}
+		if tt.entry != nil {
+			index[tt.pair.Path] = tt.entry
+		}
+		push, pull, conflicts := reconcile([]*Change{tt.pair}, index)
+		if (len(push) > 0) != tt.push || (len(pull) > 0) != tt.pull || (len(conflicts) > 0) != tt.conflicts {
+			t.Errorf("%s: reconcile() = %d push, %d pull, %d conflicts; want push %t, pull %t, conflict %t",
+				tt.name, len(push), len(pull), len(conflicts), tt.push, tt.pull, tt.conflicts)
+		}
+		if len(pull) > 0 && (pull[0].Src != tt.pair.Dest || pull[0].Dest != tt.pair.Src) {
+			t.Errorf("%s: the pull change doesn't go from remote to local", tt.name)
+		}
+	}
+}
+
+func TestReconcileKeepsDirsWithUnsyncedChanges(t *testing.T) {
+	dir := &indexEntry{Id: "d", IsDir: true}
+	f := &indexEntry{Id: "1", Size: 1, Md5: "a", ModTime: syncedAt, RemoteModTime: syncedAt}
+	index := syncIndex{"/d": dir, "/d/f": f, "/d/g": f}
+	// the directory is deleted remotely, but a file in it is edited
+	// locally and another one is untouched
+	pairs := []*Change{
+		{Path: "/d", Src: &File{IsDir: true}},
+		{Path: "/d/f", Src: localFile(2, editedAt, "b")},
+		{Path: "/d/g", Src: localFile(1, syncedAt, "a")},
+	}
+	push, pull, conflicts := reconcile(pairs, index)
+	var pulled []string
+	for _, c := range pull {
+		pulled = append(pulled, c.Path)
+	}
+	if len(push) != 0 || !reflect.DeepEqual(conflicts, []string{"/d/f"}) || !reflect.DeepEqual(pulled, []string{"/d/g"}) {
+		t.Errorf("reconcile() = %d push, pull %v, conflicts %v; want only /d/g deleted and /d/f in conflict", len(push), pulled, conflicts)
+	}
+}
+
+func TestUpdateIndex(t *testing.T) {
+	old := &indexEntry{Id: "1", Size: 1, Md5: "a", ModTime: syncedAt, RemoteModTime: syncedAt}
+	index := syncIndex{"/synced": old, "/edited": old, "/deleted": old}
+	pairs := []*Change{
+		{Path: "/synced", Src: localFile(2, editedAt, "b"), Dest: remoteFile("1", 2, editedAt, "b")},
+		{Path: "/edited", Src: localFile(2, editedAt, "b"), Dest: remoteFile("1", 1, syncedAt, "a")},
+		{Path: "/new", Src: localFile(1, editedAt, "a")},
+	}
+	got := updateIndex(index, pairs)
+	want := syncIndex{
+		"/synced": {Id: "1", Size: 2, Md5: "b", ModTime: editedAt, RemoteModTime: editedAt},
+		"/edited": old,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("updateIndex() = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// Layout of the local trash entry names, which are the ids of the
+	// runs. Each pull creates a single entry that holds everything it
+	// has deleted. The microseconds keep back to back runs apart.
+	runIdLayout = "20060102T150405.000000"
+
+	// Layout the entry names are parsed with; the fractional seconds
+	// are optional, so older entries without them still parse.
+	trashStampLayout = "20060102T150405"
+
+	// DefaultLocalTrashRetention is how long the local trash entries
+	// are kept before they are purged.
+	DefaultLocalTrashRetention = 30 * 24 * time.Hour
+)
+
+var (
+	ErrTrashEntryNotExists = errors.New("local trash entry doesn't exist")
+	ErrNotInTrash          = errors.New("path is not in the remote trash")
+)
+
+// LocalTrashList prints the files in the local trash, grouped by the
+// entry they were moved into.
+func (g *Commands) LocalTrashList() (err error) {
+	var entries []os.FileInfo
+	if entries, err = g.localTrashEntries(); err != nil {
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("Local trash is empty.")
+		return
+	}
+	for _, entry := range entries {
+		root := filepath.Join(g.context.TrashPath(), entry.Name())
+		err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil || p == root {
+				return err
+			}
+			if info.IsDir() {
+				if children, _ := ioutil.ReadDir(p); len(children) > 0 {
+					return nil
+				}
+			}
+			rel, _ := filepath.Rel(root, p)
+			fmt.Println(entry.Name(), "/"+filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// LocalTrashRestore moves a trashed file back into the context. The path
+// is of the form <entry>/<path>; if only the entry is given, everything
+// in that entry is restored. Existing local files are never overwritten.
+func (g *Commands) LocalTrashRestore() (err error) {
+	parts := strings.SplitN(strings.TrimPrefix(g.opts.Path, "/"), "/", 2)
+	entry := filepath.Join(g.context.TrashPath(), parts[0])
+	if parts[0] == "" {
+		return ErrTrashEntryNotExists
+	}
+	if _, err = os.Stat(entry); os.IsNotExist(err) {
+		return ErrTrashEntryNotExists
+	}
+	if len(parts) == 2 && parts[1] != "" {
+		return moveAll(filepath.Join(entry, parts[1]), g.context.AbsPathOf(parts[1]))
+	}
+	var children []os.FileInfo
+	if children, err = ioutil.ReadDir(entry); err != nil {
+		return
+	}
+	for _, child := range children {
+		if err = moveAll(filepath.Join(entry, child.Name()), g.context.AbsPathOf(child.Name())); err != nil {
+			return
+		}
+	}
+	return os.Remove(entry)
+}
+
+// LocalTrashPurge permanently removes the local trash entries that are
+// older than the given duration.
+func (g *Commands) LocalTrashPurge(olderThan time.Duration) (err error) {
+	var entries []os.FileInfo
+	if entries, err = g.localTrashEntries(); err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-olderThan)
+	for _, entry := range entries {
+		stamp, e := time.ParseInLocation(trashStampLayout, entry.Name(), time.Local)
+		if e != nil || !stamp.Before(cutoff) {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(g.context.TrashPath(), entry.Name())); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// RemoteTrashList prints the files in the remote trash with the paths
+// they had before they were trashed.
+func (g *Commands) RemoteTrashList() (err error) {
+	var files []*FoundFile
+	if files, err = g.rem.FindTrashed(); err != nil {
+		return
+	}
+	if len(files) == 0 {
+		fmt.Println("Remote trash is empty.")
+		return
+	}
+	for _, f := range files {
+		p := f.Path
+		if p == "" {
+			p = "?/" + f.Name
+		}
+		fmt.Println(f.Id, p)
+	}
+	return
+}
+
+// RemoteTrashRestore moves the trashed file that was at the given path
+// out of the remote trash.
+func (g *Commands) RemoteTrashRestore() (err error) {
+	var f *FoundFile
+	if f, err = g.findTrashed(g.opts.Path); err != nil {
+		return
+	}
+	return g.rem.Untrash(f.Id)
+}
+
+// RemoteTrashEmpty permanently deletes the trashed file that was at the
+// given path, or everything in the remote trash if no path is given.
+func (g *Commands) RemoteTrashEmpty() (err error) {
+	if g.opts.Path != "/" {
+		var f *FoundFile
+		if f, err = g.findTrashed(g.opts.Path); err != nil {
+			return
+		}
+		if !g.confirm(fmt.Sprintf("Permanently delete %s?", f.Path)) {
+			return
+		}
+		return g.rem.Delete(f.Id)
+	}
+	if !g.confirm("Permanently delete everything in the remote trash?") {
+		return
+	}
+	return g.rem.EmptyTrash()
+}
+
+func (g *Commands) findTrashed(p string) (*FoundFile, error) {
+	files, err := g.rem.FindTrashed()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.Path == p {
+			return f, nil
+		}
+	}
+	return nil, ErrNotInTrash
+}
+
+// confirm asks a yes/no question that defaults to no, unless prompts
+// are disabled.
+func (g *Commands) confirm(question string) bool {
+	if g.opts.IsNoPrompt {
+		return true
+	}
+	input := "N"
+	fmt.Print(question, " [y/N]: ")
+	fmt.Scanln(&input)
+	return strings.ToUpper(input) == "Y"
+}
+
+// moveToLocalTrash moves the file or directory at the context relative
+// path p into the local trash entry of the current run.
+func (g *Commands) moveToLocalTrash(p string) error {
+	dest := filepath.Join(g.context.TrashPath(), g.runId, p)
+	return moveAll(g.context.AbsPathOf(p), dest)
+}
+
+func (g *Commands) localTrashEntries() (entries []os.FileInfo, err error) {
+	var infos []os.FileInfo
+	if infos, err = ioutil.ReadDir(g.context.TrashPath()); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	for _, info := range infos {
+		if info.IsDir() {
+			entries = append(entries, info)
+		}
+	}
+	return
+}
+
+// moveAll renames src to dest, creating dest's parents if necessary. If
+// both are directories, the contents of src are merged into dest. It
+// is not an error if src has already been moved away.
+func moveAll(src, dest string) (err error) {
+	var srcInfo, destInfo os.FileInfo
+	if srcInfo, err = os.Lstat(src); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return
+	}
+	if destInfo, err = os.Lstat(dest); err == nil {
+		if !srcInfo.IsDir() || !destInfo.IsDir() {
+			return fmt.Errorf("%s already exists", dest)
+		}
+		var children []os.FileInfo
+		if children, err = ioutil.ReadDir(src); err != nil {
+			return
+		}
+		for _, child := range children {
+			if err = moveAll(filepath.Join(src, child.Name()), filepath.Join(dest, child.Name())); err != nil {
+				return
+			}
+		}
+		return os.Remove(src)
+	}
+	if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return
+	}
+	return os.Rename(src, dest)
+}
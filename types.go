@@ -21,9 +21,11 @@ import (
 	"os"
 	"time"
 
-	drive "code.google.com/p/google-api-go-client/drive/v2"
+	drive "google.golang.org/api/drive/v2"
 )
 
+const folderMimeType = "application/vnd.google-apps.folder"
+
 const (
 	OpNone = iota
 	OpAdd
@@ -32,27 +34,135 @@ const (
 )
 
 type File struct {
-	Id          string
-	Name        string
-	IsDir       bool
-	ModTime     time.Time
-	Size        int64
-	BlobAt      string
-	Md5Checksum string
+	Id          string    `json:"id,omitempty"`
+	Name        string    `json:"name"`
+	IsDir       bool      `json:"is_dir"`
+	ModTime     time.Time `json:"modified"`
+	Size        int64     `json:"size"`
+	BlobAt      string    `json:"-"`
+	Md5Checksum string    `json:"md5,omitempty"`
+	// HeadRevisionId is the id of the current revision of a remote file
+	HeadRevisionId string `json:"head_revision_id,omitempty"`
+	// Owners are the owners of a remote file
+	Owners []*User `json:"owners,omitempty"`
+	// Shared is set if a remote file is shared with others
+	Shared bool `json:"shared"`
+
+	// The rest of the metadata is only known for remote files.
+	MimeType          string     `json:"mime_type,omitempty"`
+	Description       string     `json:"description,omitempty"`
+	Parents           []string   `json:"parents,omitempty"`
+	Version           int64      `json:"version,omitempty"`
+	CreatedTime       *time.Time `json:"created,omitempty"`
+	LastModifyingUser *User      `json:"last_modifying_user,omitempty"`
+	Starred           bool       `json:"starred,omitempty"`
+	// Restricted is set if viewers can't download, print or copy it
+	Restricted     bool              `json:"restricted,omitempty"`
+	WebViewLink    string            `json:"web_view_link,omitempty"`
+	WebContentLink string            `json:"web_content_link,omitempty"`
+	ExportLinks    map[string]string `json:"export_links,omitempty"`
+	DriveId        string            `json:"drive_id,omitempty"`
+}
+
+// User is a Drive user.
+type User struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func newUser(u *drive.User) *User {
+	if u == nil {
+		return nil
+	}
+	return &User{Name: u.DisplayName, Email: u.EmailAddress}
+}
+
+func (u *User) String() string {
+	if u.Email == "" {
+		return u.Name
+	}
+	return fmt.Sprintf("%s <%s>", u.Name, u.Email)
+}
+
+// Permission is a grant of access to a remote file.
+type Permission struct {
+	Id string `json:"id"`
+	// Type is one of user, group, domain or anyone
+	Type string `json:"type"`
+	// Role is one of owner, writer, commenter or reader
+	Role     string `json:"role"`
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	WithLink bool   `json:"with_link,omitempty"`
+	// Expiration is when the permission expires, nil if it doesn't
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+func newPermission(p *drive.Permission) *Permission {
+	role := p.Role
+	for _, r := range p.AdditionalRoles {
+		if r == "commenter" {
+			role = r
+		}
+	}
+	return &Permission{
+		Id:         p.Id,
+		Type:       p.Type,
+		Role:       role,
+		Name:       p.Name,
+		Email:      p.EmailAddress,
+		Domain:     p.Domain,
+		WithLink:   p.WithLink,
+		Expiration: parseOptionalTime(time.RFC3339, p.ExpirationDate),
+	}
 }
 
 func NewRemoteFile(f *drive.File) *File {
 	mtime, _ := time.Parse("2006-01-02T15:04:05.000Z", f.ModifiedDate)
 	mtime = mtime.Round(time.Second)
+	var owners []*User
+	for _, o := range f.Owners {
+		owners = append(owners, newUser(o))
+	}
+	var parents []string
+	for _, p := range f.Parents {
+		parents = append(parents, p.Id)
+	}
 	return &File{
-		Id:          f.Id,
-		Name:        f.Title,
-		IsDir:       f.MimeType == "application/vnd.google-apps.folder",
-		ModTime:     mtime,
-		Size:        f.FileSize,
-		BlobAt:      f.DownloadUrl,
-		Md5Checksum: f.Md5Checksum,
+		Id:                f.Id,
+		Name:              f.Title,
+		IsDir:             f.MimeType == folderMimeType,
+		ModTime:           mtime,
+		Size:              f.FileSize,
+		BlobAt:            f.DownloadUrl,
+		Md5Checksum:       f.Md5Checksum,
+		HeadRevisionId:    f.HeadRevisionId,
+		Owners:            owners,
+		Shared:            f.Shared,
+		MimeType:          f.MimeType,
+		Description:       f.Description,
+		Parents:           parents,
+		Version:           f.Version,
+		CreatedTime:       parseOptionalTime("2006-01-02T15:04:05.000Z", f.CreatedDate),
+		LastModifyingUser: newUser(f.LastModifyingUser),
+		Starred:           f.Labels != nil && f.Labels.Starred,
+		Restricted:        f.Labels != nil && f.Labels.Restricted,
+		WebViewLink:       f.AlternateLink,
+		WebContentLink:    f.WebContentLink,
+		ExportLinks:       f.ExportLinks,
+		DriveId:           f.DriveId,
+	}
+}
+
+// parseOptionalTime parses the value, returning nil if it is empty or
+// invalid.
+func parseOptionalTime(layout, value string) *time.Time {
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return nil
 	}
+	return &t
 }
 
 func NewLocalFile(absPath string, f os.FileInfo) *File {
@@ -66,6 +176,45 @@ func NewLocalFile(absPath string, f os.FileInfo) *File {
 	}
 }
 
+// Revision is a stored version of the content of a remote file.
+type Revision struct {
+	Id          string
+	ModTime     time.Time
+	Author      *User
+	Size        int64
+	Md5Checksum string
+	MimeType    string
+	// Pinned is set if the revision is kept forever
+	Pinned bool
+}
+
+func newRevision(r *drive.Revision) *Revision {
+	mtime, _ := time.Parse("2006-01-02T15:04:05.000Z", r.ModifiedDate)
+	return &Revision{
+		Id:          r.Id,
+		ModTime:     mtime.Round(time.Second),
+		Author:      newUser(r.LastModifyingUser),
+		Size:        r.FileSize,
+		Md5Checksum: r.Md5Checksum,
+		MimeType:    r.MimeType,
+		Pinned:      r.Pinned,
+	}
+}
+
+// Drive is a shared drive.
+type Drive struct {
+	Id   string
+	Name string
+}
+
+// FoundFile is a remote file found by a query and its path in the
+// context; for a file in the trash, the path it had before it was
+// trashed.
+type FoundFile struct {
+	*File
+	Path string
+}
+
 type Change struct {
 	Path string
 	Src  *File
@@ -73,7 +222,10 @@ type Change struct {
 }
 
 func (c *Change) Symbol() string {
-	op := c.Op()
+	return opSymbol(c.Op())
+}
+
+func opSymbol(op int) string {
 	switch op {
 	case OpAdd:
 		return "\x1b[32m+\x1b[0m"
@@ -0,0 +1,45 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionalTimesAreOmitted(t *testing.T) {
+	created := time.Date(2014, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		v       interface{}
+		key     string
+		present bool
+	}{
+		{&File{}, `"created"`, false},
+		{&File{CreatedTime: &created}, `"created":"2014-01-02T03:04:05Z"`, true},
+		{&Permission{}, `"expiration"`, false},
+		{&Permission{Expiration: &created}, `"expiration":"2014-01-02T03:04:05Z"`, true},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), tt.key) != tt.present {
+			t.Errorf("json.Marshal(%+v) = %s; want %s present %t", tt.v, data, tt.key, tt.present)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rakyll/drive/config"
+	"gopkg.in/fsnotify.v1"
+)
+
+const (
+	// DefaultWatchDebounce is how long watch waits for a burst of local
+	// changes to settle before pushing them.
+	DefaultWatchDebounce = 2 * time.Second
+
+	// DefaultWatchPollInterval is how often watch pulls remote changes.
+	DefaultWatchPollInterval = time.Minute
+)
+
+// Watch pushes local changes under Path as they happen and pulls remote
+// changes periodically, until it is interrupted. Bursts of changes are
+// batched: a push starts once there have been no changes for debounce.
+// Every push and pull runs without prompts and within the context lock.
+func (g *Commands) Watch(debounce, pollInterval time.Duration) (err error) {
+	var w *fsnotify.Watcher
+	if w, err = fsnotify.NewWatcher(); err != nil {
+		return
+	}
+	defer w.Close()
+	if g.sparse, err = g.context.ReadSparse(); err != nil {
+		return
+	}
+	if err = g.watchRecv(w, g.opts.Path); err != nil {
+		return
+	}
+	g.opts.IsNoPrompt = true
+	g.opts.IsInteractive = false
+	root := g.opts.Path
+
+	fmt.Println("Watching", g.context.AbsPathOf(root))
+	pending := make(map[string]bool)
+	// the paths written by the last pull and until when their events
+	// are ignored
+	var (
+		pulled      map[string]bool
+		pulledUntil time.Time
+	)
+	settle := time.NewTimer(debounce)
+	settle.Stop()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	push := func() (err error) {
+		for _, p := range minimalPaths(pending) {
+			if e := g.watchRun("push", p, g.Push); e != nil {
+				err = e
+				continue
+			}
+			for q := range pending {
+				if isUnderAny(q, map[string]bool{p: true}) {
+					delete(pending, q)
+				}
+			}
+		}
+		return
+	}
+	for {
+		select {
+		case ev := <-w.Events:
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			p, ok := g.contextPath(ev.Name)
+			if !ok || !g.isWatched(p) {
+				continue
+			}
+			if ev.Op&fsnotify.Create != 0 {
+				if info, e := os.Stat(ev.Name); e == nil && info.IsDir() {
+					g.watchRecv(w, p)
+				}
+			}
+			if time.Now().Before(pulledUntil) && isUnderAny(p, pulled) {
+				// written by the pull, not by the user
+				continue
+			}
+			pending[p] = true
+			settle.Reset(debounce)
+		case err := <-w.Errors:
+			fmt.Println("watch:", err)
+		case <-settle.C:
+			push()
+		case <-poll.C:
+			// push the pending changes first, so that the pull doesn't
+			// overwrite them; if they can't be pushed, don't pull
+			settle.Stop()
+			if push() != nil {
+				settle.Reset(debounce)
+				continue
+			}
+			if g.watchRun("pull", root, g.Pull) == nil {
+				pulled = g.runPaths(g.runId)
+				pulledUntil = time.Now().Add(debounce)
+			}
+		}
+	}
+}
+
+// watchRun runs a push or pull of p within the context lock. Its errors
+// are reported and returned.
+func (g *Commands) watchRun(command, p string, fn func() error) (err error) {
+	var l *config.Lock
+	if l, err = g.context.Lock("watch", true); err != nil {
+		fmt.Println("watch:", err)
+		return
+	}
+	defer l.Unlock()
+	g.newRun()
+	g.opts.Path = p
+	if err = fn(); err != nil {
+		fmt.Printf("watch: %s %s: %v\n", command, p, err)
+	}
+	return
+}
+
+// watchRecv adds watches for the directory at the context relative
+// path p and the directories under it.
+func (g *Commands) watchRecv(w *fsnotify.Watcher, p string) error {
+	return filepath.Walk(g.context.AbsPathOf(p), func(abs string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		rel, ok := g.contextPath(abs)
+		if !ok || !g.isWatched(rel) {
+			return filepath.SkipDir
+		}
+		return w.Add(abs)
+	})
+}
+
+// isWatched reports whether changes to the context relative path p
+// should be pushed.
+func (g *Commands) isWatched(p string) bool {
+	if p == "/" {
+		return true
+	}
+	if p == "/.gd" || strings.HasPrefix(p, "/.gd/") {
+		return false
+	}
+	if !g.opts.Hidden && strings.HasPrefix(path.Base(p), ".") {
+		return false
+	}
+	return !g.isIgnored(p) && g.isSparseIncluded(p)
+}
+
+// contextPath returns the context relative path of an absolute path.
+func (g *Commands) contextPath(abs string) (string, bool) {
+	rel, err := filepath.Rel(g.context.AbsPath, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return path.Join("/", filepath.ToSlash(rel)), true
+}
+
+// minimalPaths returns the paths in the set that are not under another
+// path in the set, in order.
+func minimalPaths(set map[string]bool) (paths []string) {
+	var all []string
+	for p := range set {
+		all = append(all, p)
+	}
+	sort.Strings(all)
+	for _, p := range all {
+		if n := len(paths); n > 0 && (paths[n-1] == "/" || strings.HasPrefix(p, paths[n-1]+"/")) {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	return
+}
+
+// isUnderAny reports whether p is one of the paths in the set or
+// under one of them.
+func isUnderAny(p string, set map[string]bool) bool {
+	for ; ; p = path.Dir(p) {
+		if set[p] {
+			return true
+		}
+		if p == "/" {
+			return false
+		}
+	}
+}
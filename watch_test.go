@@ -0,0 +1,60 @@
+// Copyright 2013 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMinimalPaths(t *testing.T) {
+	tests := []struct {
+		set  []string
+		want []string
+	}{
+		{[]string{"/a", "/a/b", "/ab"}, []string{"/a", "/ab"}},
+		{[]string{"/a/b/c", "/a/b"}, []string{"/a/b"}},
+		{[]string{"/", "/a"}, []string{"/"}},
+	}
+	for _, tt := range tests {
+		set := make(map[string]bool)
+		for _, p := range tt.set {
+			set[p] = true
+		}
+		if got := minimalPaths(set); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("minimalPaths(%v) = %v; want %v", tt.set, got, tt.want)
+		}
+	}
+}
+
+func TestIsUnderAny(t *testing.T) {
+	set := map[string]bool{"/a/b": true, "/c": true}
+	tests := []struct {
+		p    string
+		want bool
+	}{
+		{"/a/b", true},
+		{"/a/b/x", true},
+		{"/c/d/e", true},
+		{"/a", false},
+		{"/a/bc", false},
+		{"/", false},
+	}
+	for _, tt := range tests {
+		if got := isUnderAny(tt.p, set); got != tt.want {
+			t.Errorf("isUnderAny(%s) = %t; want %t", tt.p, got, tt.want)
+		}
+	}
+}